@@ -0,0 +1,111 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// isRawBytes reports whether a slice or an array of elem holds raw bytes, decoded and encoded as a whole with the
+// encoding option of the field. A named byte type with a registered parser, an enumeration or a parsing interface
+// is handled element by element instead, like any other element type.
+func (e *Enviro) isRawBytes(elem reflect.Type, opts Options) bool {
+	if elem.Kind() != reflect.Uint8 {
+		return false
+	}
+	if _, ok := e.lookupParser(elem); ok {
+		return false
+	}
+	if table, _ := lookupEnumTable(elem, opts.nested()); table != nil {
+		return false
+	}
+	ptr := reflect.PointerTo(elem)
+	for _, typ := range []reflect.Type{parserWithOptionsType, parserType, textUnmarshalerType, flagValueType, formatterType, textMarshalerType} {
+		if ptr.Implements(typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// setBytesField sets a byte slice from the value decoded with the encoding option of the field.
+func (e *Enviro) setBytesField(field reflect.Value, value string, opts Options) error {
+	b, err := decodeBytes(value, opts)
+	if err != nil {
+		return err
+	}
+	field.SetBytes(b)
+	return nil
+}
+
+// setByteArrayField is like setBytesField for byte arrays, the decoded value must have the exact length of the
+// array.
+func (e *Enviro) setByteArrayField(field reflect.Value, value string, opts Options) error {
+	b, err := decodeBytes(value, opts)
+	if err != nil {
+		return err
+	}
+	if len(b) != field.Len() {
+		return fmt.Errorf("expected %d bytes, got %d", field.Len(), len(b))
+	}
+	for i, c := range b {
+		field.Index(i).SetUint(uint64(c))
+	}
+	return nil
+}
+
+// decodeBytes decodes value according to the `hex`, `base64`, `base64url` or `raw` option. Without any of them,
+// the value is taken as is. Base64 input may be given with or without padding, and whitespace is ignored in hex
+// and base64 input so that long values can be wrapped.
+func decodeBytes(value string, opts Options) ([]byte, error) {
+	switch {
+	case opts.Has("hex"):
+		b, err := hex.DecodeString(stripSpaces(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex value: %w", err)
+		}
+		return b, nil
+	case opts.Has("base64"):
+		b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(stripSpaces(value), "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 value: %w", err)
+		}
+		return b, nil
+	case opts.Has("base64url"):
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(stripSpaces(value), "="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64url value: %w", err)
+		}
+		return b, nil
+	}
+	return []byte(value), nil
+}
+
+// decodeBlob decodes a JSON or YAML document that was encoded to survive shell quoting, e.g. with
+// `envopt:"json base64"`. Values without an encoding option are returned unchanged.
+func decodeBlob(value string, opts Options) (string, error) {
+	if !opts.Has("hex") && !opts.Has("base64") && !opts.Has("base64url") {
+		return value, nil
+	}
+	b, err := decodeBytes(value, opts)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func stripSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
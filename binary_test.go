@@ -0,0 +1,158 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testLevel uint8
+
+type testOctet uint8
+
+func (o *testOctet) UnmarshalText(text []byte) error {
+	var v uint8
+	if _, err := fmt.Sscanf(string(text), "o%d", &v); err != nil {
+		return fmt.Errorf("invalid octet %q", text)
+	}
+	*o = testOctet(v)
+	return nil
+}
+
+func (o testOctet) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("o%d", o)), nil
+}
+
+func TestParseEnvBinary(t *testing.T) {
+	type Settings struct {
+		Name  string `json:"name" yaml:"name"`
+		Level int    `json:"level" yaml:"level"`
+	}
+
+	type Config struct {
+		Raw      []byte            `enviro:"raw"`
+		Hex      []byte            `enviro:"hex" envopt:"hex"`
+		Base64   []byte            `enviro:"b64" envopt:"base64"`
+		URL      *[]byte           `enviro:"b64url" envopt:"base64url"`
+		Keys     [][]byte          `enviro:"keys" envopt:"hex"`
+		Salt     [4]byte           `enviro:"salt"`
+		Settings Settings          `enviro:"settings" envopt:"json base64"`
+		Labels   map[string]string `enviro:"labels" envopt:"yaml base64url"`
+	}
+
+	env := map[string]string{
+		"RAW":      "secret",
+		"HEX":      "de ad\nbe ef",
+		"B64":      "AQID",
+		"B64URL":   "-_8",
+		"KEYS":     "00ff,ff00",
+		"SALT":     "abcd",
+		"SETTINGS": base64.StdEncoding.EncodeToString([]byte(`{"name": "a, b", "level": 2}`)),
+		"LABELS":   base64.RawURLEncoding.EncodeToString([]byte("team: core\ntier: 1")),
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse binary environment variables: %s", err)
+	}
+
+	url := []byte{0xfb, 0xff}
+	expected := Config{
+		Raw:      []byte("secret"),
+		Hex:      []byte{0xde, 0xad, 0xbe, 0xef},
+		Base64:   []byte{1, 2, 3},
+		URL:      &url,
+		Keys:     [][]byte{{0x00, 0xff}, {0xff, 0x00}},
+		Salt:     [4]byte{'a', 'b', 'c', 'd'},
+		Settings: Settings{Name: "a, b", Level: 2},
+		Labels:   map[string]string{"team": "core", "tier": "1"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	os.Setenv("HEX", "xyz")
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for an invalid hex value")
+	}
+}
+
+func TestParseEnvNamedByteElements(t *testing.T) {
+	type Config struct {
+		Levels []testLevel  `enviro:"levels"`
+		Octets [2]testOctet `enviro:"octets"`
+		Kinds  []uint8      `enviro:"kinds" envopt:"enum:a,b,c"`
+		Raw    []byte       `enviro:"raw"`
+		Named  []namedByte  `enviro:"named"`
+		Block  [2]namedByte `enviro:"block" envopt:"hex"`
+	}
+
+	restoreRegistry(t)
+	RegisterEnum(map[string]testLevel{"low": 1, "high": 2})
+
+	env := map[string]string{
+		"LEVELS": "low,high",
+		"OCTETS": "o1,o2",
+		"KINDS":  "c,a",
+		"RAW":    "low,high",
+		"NAMED":  "ab",
+		"BLOCK":  "0a0b",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	expected := Config{
+		Levels: []testLevel{1, 2},
+		Octets: [2]testOctet{1, 2},
+		Kinds:  []uint8{2, 0},
+		Raw:    []byte("low,high"),
+		Named:  []namedByte("ab"),
+		Block:  [2]namedByte{10, 11},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	marshaled, err := e.Marshal(&config)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %s", err)
+	}
+	want := []string{"LEVELS=low,high", "OCTETS=o1,o2", "KINDS=c,a", "RAW=low,high", "NAMED=ab", "BLOCK=0a0b"}
+	if !reflect.DeepEqual(marshaled, want) {
+		t.Errorf("Expected %+v, got %+v", want, marshaled)
+	}
+
+	os.Setenv("LEVELS", "low,medium")
+	if err := e.ParseEnv(&Config{}); err == nil || !strings.Contains(err.Error(), `invalid value "medium"`) {
+		t.Errorf("Expected an invalid element error, got %v", err)
+	}
+}
+
+type namedByte uint8
@@ -0,0 +1,122 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// BoolMode controls the values accepted for boolean fields.
+type BoolMode int
+
+const (
+	// BoolStandard accepts the values understood by strconv.ParseBool: 1, t, T, TRUE, true, True, 0, f, F,
+	// FALSE, false and False.
+	BoolStandard BoolMode = iota
+	// BoolExtended accepts the values of BoolStandard as well as yes, y, on, enabled, enable, no, n, off,
+	// disabled and disable, case-insensitively.
+	BoolExtended
+	// BoolStrict accepts only true and false.
+	BoolStrict
+)
+
+var boolModes = map[string]BoolMode{
+	"standard": BoolStandard,
+	"extended": BoolExtended,
+	"strict":   BoolStrict,
+}
+
+var extendedBools = map[string]bool{
+	"yes":      true,
+	"y":        true,
+	"on":       true,
+	"enabled":  true,
+	"enable":   true,
+	"no":       false,
+	"n":        false,
+	"off":      false,
+	"disabled": false,
+	"disable":  false,
+}
+
+// SetBoolMode sets the values accepted for boolean fields that don't specify a mode with the `bool` option.
+// The default mode is BoolStandard.
+func (e *Enviro) SetBoolMode(mode BoolMode) {
+	e.boolMode = mode
+}
+
+// setBoolField parses a boolean according to the `bool` option of the field, which holds a mode ("standard",
+// "extended" or "strict") and/or "presence" separated by "|". A presence flag is true when the variable is set
+// with an empty value, e.g. `envopt:"bool:extended|presence"`.
+func (e *Enviro) setBoolField(field reflect.Value, value string, opts Options) error {
+	mode := e.boolMode
+	var presence bool
+	if flags, ok := opts.Lookup("bool"); ok {
+		for _, flag := range strings.Split(flags, "|") {
+			flag = strings.TrimSpace(flag)
+			if flag == "presence" {
+				presence = true
+				continue
+			}
+			m, ok := boolModes[flag]
+			if !ok {
+				return fmt.Errorf("unsupported bool option %q", flag)
+			}
+			mode = m
+		}
+	}
+
+	if presence && value == "" {
+		field.SetBool(true)
+		return nil
+	}
+
+	b, err := parseBool(value, mode)
+	if err != nil {
+		return err
+	}
+	field.SetBool(b)
+	return nil
+}
+
+func parseBool(value string, mode BoolMode) (bool, error) {
+	switch mode {
+	case BoolStrict:
+		switch value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return false, fmt.Errorf("invalid boolean %q: expected true or false", value)
+	case BoolExtended:
+		lower := strings.ToLower(value)
+		if b, ok := extendedBools[lower]; ok {
+			return b, nil
+		}
+		if b, err := strconv.ParseBool(lower); err == nil {
+			return b, nil
+		}
+		return false, fmt.Errorf("invalid boolean %q", value)
+	}
+	return strconv.ParseBool(value)
+}
+
+// isPresenceFlag reports whether the options declare a presence flag.
+func isPresenceFlag(opts Options) bool {
+	flags, ok := opts.Lookup("bool")
+	if !ok {
+		return false
+	}
+	for _, flag := range strings.Split(flags, "|") {
+		if strings.TrimSpace(flag) == "presence" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvBoolModes(t *testing.T) {
+	type Config struct {
+		Debug    bool            `enviro:"debug" envopt:"bool:extended"`
+		Features []bool          `enviro:"features" envopt:"bool:extended"`
+		Toggles  map[string]bool `enviro:"toggles" envopt:"bool:extended"`
+		Verbose  bool            `enviro:"verbose" envopt:"bool:presence" envdefault:"false"`
+		Quiet    *bool           `enviro:"quiet" envopt:"bool:presence"`
+		Trace    bool            `enviro:"trace" envopt:"bool:presence" envdefault:"true"`
+	}
+
+	os.Setenv("DEBUG", "Yes")
+	os.Setenv("FEATURES", "on, OFF, enabled, n, 1")
+	os.Setenv("TOGGLES", "cache=disabled, gzip=Enable")
+	os.Setenv("VERBOSE", "")
+	os.Setenv("QUIET", "false")
+	defer func() {
+		os.Unsetenv("DEBUG")
+		os.Unsetenv("FEATURES")
+		os.Unsetenv("TOGGLES")
+		os.Unsetenv("VERBOSE")
+		os.Unsetenv("QUIET")
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse bool environment variables: %s", err)
+	}
+
+	quiet := false
+	expected := Config{
+		Debug:    true,
+		Features: []bool{true, false, true, false, true},
+		Toggles:  map[string]bool{"cache": false, "gzip": true},
+		Verbose:  true,
+		Quiet:    &quiet,
+		Trace:    true,
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+}
+
+func TestParseEnvBoolInstanceMode(t *testing.T) {
+	type Config struct {
+		Enabled bool `enviro:"enabled"`
+		Legacy  bool `enviro:"legacy" envopt:"bool:standard"`
+	}
+
+	os.Setenv("ENABLED", "on")
+	os.Setenv("LEGACY", "1")
+	defer func() {
+		os.Unsetenv("ENABLED")
+		os.Unsetenv("LEGACY")
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for \"on\" in standard mode")
+	}
+
+	e.SetBoolMode(BoolExtended)
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse bool environment variables: %s", err)
+	}
+	if !config.Enabled || !config.Legacy {
+		t.Errorf("Expected both flags to be true, got %+v", config)
+	}
+
+	e.SetBoolMode(BoolStrict)
+	os.Setenv("ENABLED", "1")
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for \"1\" in strict mode")
+	}
+	os.Setenv("ENABLED", "true")
+	if err := e.ParseEnv(&config); err != nil {
+		t.Errorf("Failed to parse bool environment variables: %s", err)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"text/template"
+	"time"
+)
+
+type fieldSetter func(e *Enviro, field reflect.Value, value string, opts Options) error
+
+type fieldFormatter func(e *Enviro, field reflect.Value, opts Options) (string, error)
+
+// builtinSetters maps the types that Enviro knows how to parse to their setter. They are consulted before the
+// standard parsing interfaces, so that a type like time.Time keeps its flexible layouts instead of being parsed
+// by its UnmarshalText method.
+var builtinSetters = map[reflect.Type]fieldSetter{
+	reflect.TypeOf(time.Duration(0)):                 setDuration,
+	reflect.TypeOf(time.Time{}):                      setTime,
+	reflect.TypeOf(time.Location{}):                  setLocation,
+	reflect.TypeOf(url.URL{}):                        setURL,
+	reflect.TypeOf(os.File{}):                        setFile,
+	reflect.TypeOf((*os.File)(nil)):                  setFilePtr,
+	reflect.TypeOf((*File)(nil)):                     setEnviroFile,
+	reflect.TypeOf(Path("")):                         setPath,
+	reflect.TypeOf(net.IP(nil)):                      setIP,
+	reflect.TypeOf(net.IPNet{}):                      setIPNet,
+	reflect.TypeOf(net.HardwareAddr(nil)):            setHardwareAddr,
+	reflect.TypeOf(netip.Addr{}):                     setAddr,
+	reflect.TypeOf(netip.Prefix{}):                   setPrefix,
+	reflect.TypeOf(netip.AddrPort{}):                 setAddrPort,
+	reflect.TypeOf(HostPort{}):                       setHostPort,
+	reflect.TypeOf(ByteSize(0)):                      setByteSize,
+	reflect.TypeOf(os.FileMode(0)):                   setFileMode,
+	reflect.TypeOf((*x509.Certificate)(nil)):         setCertificate,
+	reflect.TypeOf([]*x509.Certificate(nil)):         setCertificates,
+	reflect.TypeOf((*x509.CertPool)(nil)):            setCertPool,
+	reflect.TypeOf((*crypto.PrivateKey)(nil)).Elem(): setPrivateKey,
+	reflect.TypeOf((*crypto.Signer)(nil)).Elem():     setPrivateKey,
+	reflect.TypeOf(tls.Certificate{}):                setTLSCertificate,
+	reflect.TypeOf((*regexp.Regexp)(nil)):            setRegexp,
+	reflect.TypeOf((*template.Template)(nil)):        setTemplate,
+	reflect.TypeOf(mail.Address{}):                   setMailAddress,
+}
+
+// builtinFormatters maps the built-in types to their formatter, the inverse of their setter, used by Marshal.
+var builtinFormatters = map[reflect.Type]fieldFormatter{
+	reflect.TypeOf(time.Duration(0)):                 formatDuration,
+	reflect.TypeOf(time.Time{}):                      formatTime,
+	reflect.TypeOf(time.Location{}):                  formatStringer,
+	reflect.TypeOf(url.URL{}):                        formatStringer,
+	reflect.TypeOf((*os.File)(nil)):                  formatFile,
+	reflect.TypeOf((*File)(nil)):                     formatFile,
+	reflect.TypeOf(net.IP(nil)):                      formatStringer,
+	reflect.TypeOf(net.IPNet{}):                      formatStringer,
+	reflect.TypeOf(net.HardwareAddr(nil)):            formatStringer,
+	reflect.TypeOf(netip.Addr{}):                     formatStringer,
+	reflect.TypeOf(netip.Prefix{}):                   formatStringer,
+	reflect.TypeOf(netip.AddrPort{}):                 formatStringer,
+	reflect.TypeOf(HostPort{}):                       formatStringer,
+	reflect.TypeOf(ByteSize(0)):                      formatStringer,
+	reflect.TypeOf(os.FileMode(0)):                   formatFileMode,
+	reflect.TypeOf((*x509.Certificate)(nil)):         formatCertificate,
+	reflect.TypeOf([]*x509.Certificate(nil)):         formatCertificates,
+	reflect.TypeOf((*x509.CertPool)(nil)):            formatCertPool,
+	reflect.TypeOf((*crypto.PrivateKey)(nil)).Elem(): formatPrivateKey,
+	reflect.TypeOf((*crypto.Signer)(nil)).Elem():     formatPrivateKey,
+	reflect.TypeOf(tls.Certificate{}):                formatTLSCertificate,
+	reflect.TypeOf((*regexp.Regexp)(nil)):            formatStringer,
+	reflect.TypeOf((*template.Template)(nil)):        formatTemplate,
+	reflect.TypeOf(mail.Address{}):                   formatStringer,
+}
+
+func setTime(e *Enviro, field reflect.Value, value string, opts Options) error {
+	layouts, location := parseTimeFormatTag(opts)
+	return e.setTimeField(field, value, layouts, location)
+}
+
+func setLocation(_ *Enviro, field reflect.Value, value string, _ Options) error {
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(*loc))
+	return nil
+}
+
+// setFile rejects os.File value fields, which would hold a copy of the *os.File opened by the parser, as the os
+// package does not allow.
+func setFile(_ *Enviro, _ reflect.Value, _ string, _ Options) error {
+	return errors.New("os.File value fields are not supported, use *os.File or *enviro.File")
+}
+
+func setByteSize(e *Enviro, field reflect.Value, value string, _ Options) error {
+	return e.setByteSizeField(field, value)
+}
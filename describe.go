@@ -0,0 +1,134 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Sources of a field value reported by Describe. A Watcher reports more precise sources for the values read from
+// a secret directory ("secret:" followed by the file path) or a .env file ("dotenv:" followed by the file path).
+const (
+	// SourceEnv means the value comes from the environment variable.
+	SourceEnv = "env"
+	// SourceDefault means the value comes from the envdefault tag.
+	SourceDefault = "default"
+	// SourceUnset means the variable is not set and the field has no default.
+	SourceUnset = "unset"
+)
+
+// FieldInfo describes a field of a configuration and its current value.
+type FieldInfo struct {
+	// Key is the environment variable name.
+	Key string `json:"key"`
+	// Field is the path of the struct field, e.g. "Proxy.URL".
+	Field string `json:"field"`
+	// Type is the Go type of the field.
+	Type string `json:"type"`
+	// Value is the value formatted as Marshal would, or "[REDACTED]" for secrets.
+	Value string `json:"value"`
+	// Set reports whether the field has a value, as opposed to a nil pointer, slice or map.
+	Set bool `json:"set"`
+	// Source is where the value comes from, one of SourceEnv, SourceDefault or SourceUnset, or a more precise
+	// source for a Watcher.
+	Source string `json:"source"`
+	// Default is the value of the envdefault tag.
+	Default string `json:"default,omitempty"`
+	// Required reports whether the variable is required.
+	Required bool `json:"required,omitempty"`
+	// Secret reports whether the value is redacted.
+	Secret bool `json:"secret,omitempty"`
+	// Description is the value of the envdesc tag.
+	Description string `json:"description,omitempty"`
+}
+
+// Describer describes a configuration. It is implemented by Watcher, and returned by Enviro.Describer for a
+// configuration parsed with ParseEnv.
+type Describer interface {
+	Describe() ([]FieldInfo, error)
+}
+
+// DescriberFunc is an adapter to use a function as a Describer.
+type DescriberFunc func() ([]FieldInfo, error)
+
+// Describe calls f.
+func (f DescriberFunc) Describe() ([]FieldInfo, error) {
+	return f()
+}
+
+// Describe returns the description of every field of config, in field order, with secret values redacted. The
+// source of each value is inferred from the current environment, so it may not reflect a variable changed after
+// config was parsed.
+func (e *Enviro) Describe(config any) ([]FieldInfo, error) {
+	lookup := e.lookupFunc()
+	return e.describe(config, func(key string) (string, string, bool) {
+		value, ok := lookup(key)
+		return value, SourceEnv, ok
+	})
+}
+
+// Describer returns a Describer for config, which must not be modified while it is described.
+func (e *Enviro) Describer(config any) Describer {
+	return DescriberFunc(func() ([]FieldInfo, error) {
+		return e.Describe(config)
+	})
+}
+
+// unformattable returns the placeholder described and logged for a value that can't be formatted, such as a
+// certificate pool or a type with a registered parser but no formatter. Printing it with fmt would dump all its
+// fields, secrets included.
+func unformattable(field reflect.Value) string {
+	return "[unformattable " + unwrapDynamic(field).Type().String() + "]"
+}
+
+func (e *Enviro) describe(config any, lookup func(key string) (value, source string, ok bool)) ([]FieldInfo, error) {
+	var fields []FieldInfo
+	err := walkFields(config, e.prefix, func(bf boundField) error {
+		info := FieldInfo{
+			Key:         bf.key,
+			Field:       bf.name,
+			Type:        bf.field.Type.String(),
+			Default:     bf.def,
+			Required:    bf.required,
+			Secret:      bf.secret,
+			Description: bf.field.Tag.Get("envdesc"),
+		}
+
+		value, err := e.formatField(bf.value, bf.opts)
+		switch {
+		case errors.Is(err, errUnset):
+		case err != nil:
+			info.Value, info.Set = unformattable(bf.value), true
+		default:
+			info.Value, info.Set = value, true
+		}
+		if info.Secret && info.Value != "" {
+			info.Value = redacted
+		}
+
+		info.Source = valueSource(bf, lookup)
+		fields = append(fields, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// valueSource returns the source of the value of bf. It mirrors the rules of ParseEnv: an empty variable falls
+// back to the default, unless it is a presence flag.
+func valueSource(bf boundField, lookup func(key string) (value, source string, ok bool)) string {
+	value, source, exists := lookup(bf.key)
+	switch {
+	case exists && (value != "" || bf.def == "" || isPresenceFlag(bf.opts)):
+		return source
+	case bf.def != "":
+		return SourceDefault
+	default:
+		return SourceUnset
+	}
+}
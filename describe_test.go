@@ -0,0 +1,138 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	type Proxy struct {
+		Host string `enviro:"host" envdefault:"localhost"`
+	}
+
+	type Config struct {
+		Level    string   `enviro:"level" envdefault:"info" envdesc:"Log level."`
+		Port     int      `enviro:"port,required"`
+		Password string   `enviro:"password,secret"`
+		Hosts    []string `enviro:"hosts"`
+		Proxy    Proxy    `enviro:"nested:proxy"`
+	}
+
+	os.Setenv("APP_PORT", "8080")
+	os.Setenv("APP_PASSWORD", "s3cr3t")
+	os.Setenv("APP_PROXY_HOST", "")
+	defer os.Unsetenv("APP_PORT")
+	defer os.Unsetenv("APP_PASSWORD")
+	defer os.Unsetenv("APP_PROXY_HOST")
+
+	e := New()
+	e.SetEnvPrefix("APP")
+	var cfg Config
+	if err := e.ParseEnv(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := e.Describer(&cfg).Describe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FieldInfo{
+		{Key: "APP_LEVEL", Field: "Level", Type: "string", Value: "info", Set: true, Source: SourceDefault, Default: "info", Description: "Log level."},
+		{Key: "APP_PORT", Field: "Port", Type: "int", Value: "8080", Set: true, Source: SourceEnv, Required: true},
+		{Key: "APP_PASSWORD", Field: "Password", Type: "string", Value: redacted, Set: true, Source: SourceEnv, Secret: true},
+		{Key: "APP_HOSTS", Field: "Hosts", Type: "[]string", Source: SourceUnset},
+		{Key: "APP_PROXY_HOST", Field: "Proxy.Host", Type: "string", Value: "localhost", Set: true, Source: SourceDefault, Default: "localhost"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, fields)
+	}
+}
+
+func TestWatcherDescribe(t *testing.T) {
+	type Config struct {
+		Level    string `enviro:"level"`
+		Limit    int    `enviro:"limit" envdefault:"5"`
+		Password string `enviro:"db_password,secret"`
+	}
+
+	dir := t.TempDir()
+	dotEnv := filepath.Join(dir, ".env")
+	if err := os.WriteFile(dotEnv, []byte("LEVEL=debug\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "db_password"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWatcher[Config](New())
+	if _, err := w.Describe(); err == nil {
+		t.Errorf("Expected an error before the configuration is loaded")
+	}
+
+	w.AddDotEnv(dotEnv)
+	w.AddSecretDir(dir)
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	fields, err := w.Describe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := make(map[string]string)
+	for _, f := range fields {
+		sources[f.Key] = f.Source
+		if f.Key == "DB_PASSWORD" && f.Value != redacted {
+			t.Errorf("Expected the password to be redacted, got %q", f.Value)
+		}
+	}
+	expected := map[string]string{
+		"LEVEL":       "dotenv:" + dotEnv,
+		"LIMIT":       SourceDefault,
+		"DB_PASSWORD": "secret:" + filepath.Join(dir, "db_password"),
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, sources)
+	}
+}
+
+type testCredentials struct {
+	User     string
+	Password string
+}
+
+func TestDescribeUnformattable(t *testing.T) {
+	type Config struct {
+		DB testCredentials `enviro:"db"`
+	}
+
+	e := New()
+	RegisterInstanceParser(e, func(value string) (testCredentials, error) {
+		user, password, _ := strings.Cut(value, ":")
+		return testCredentials{User: user, Password: password}, nil
+	})
+	cfg := Config{DB: testCredentials{User: "app", Password: "s3cr3t"}}
+
+	// A value without a formatter is described by its type, rather than printed with its fields
+	placeholder := "[unformattable enviro.testCredentials]"
+	fields, err := e.Describe(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 || fields[0].Value != placeholder {
+		t.Errorf("Expected %s, got %+v", placeholder, fields)
+	}
+
+	expected := []slog.Attr{slog.String("db", placeholder)}
+	if attrs := e.LogValuer(&cfg).LogValue().Group(); !reflect.DeepEqual(attrs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, attrs)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// redacted replaces the value of secret fields in diffs, logs and reports.
+const redacted = "[REDACTED]"
+
+// ChangeKind is the kind of a FieldChange.
+type ChangeKind string
+
+const (
+	// ChangeAdded means the value was unset and is now set.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved means the value was set and is now unset.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified means the value changed.
+	ChangeModified ChangeKind = "modified"
+)
+
+// FieldChange is a change between two configurations, as reported by Diff.
+type FieldChange struct {
+	// Key is the environment variable name.
+	Key string `json:"key"`
+	// Path is the environment variable name followed by the index of the slice or array element, or the key of
+	// the map entry, that changed, e.g. "HOSTS[2]" or "LIMITS[read]". It is the key for other changes.
+	Path string `json:"path"`
+	// Field is the path of the struct field, e.g. "Proxy.URL".
+	Field string `json:"field"`
+	// Kind is the kind of change.
+	Kind ChangeKind `json:"kind"`
+	// Old is the previous value, formatted as Marshal would, or "[REDACTED]" for secrets.
+	Old string `json:"old,omitempty"`
+	// New is the new value, formatted as Marshal would, or "[REDACTED]" for secrets.
+	New string `json:"new,omitempty"`
+}
+
+// Diff is like Enviro.Diff with a default Enviro instance.
+func Diff(old, new any) ([]FieldChange, error) {
+	return New().Diff(old, new)
+}
+
+// Diff compares two configurations of the same type, parsed by ParseEnv, and returns their changes in field
+// order. Values are compared by their formatted form, as produced by Marshal, so that slices and maps are compared
+// element by element. The values of secret fields, flagged with `enviro:"name,secret"`, and of private keys are
+// redacted. PEM data read from a file is compared by its content. A *x509.CertPool, and the values of types with
+// a registered parser but no formatter, can't be formatted and are excluded: register a formatter with
+// RegisterFormatter to compare them. Any other value that can't be formatted results in an error.
+func (e *Enviro) Diff(old, new any) ([]FieldChange, error) {
+	if reflect.TypeOf(old) != reflect.TypeOf(new) {
+		return nil, fmt.Errorf("cannot diff %T and %T", old, new)
+	}
+	before, err := e.flatten(old)
+	if err != nil {
+		return nil, err
+	}
+	after, err := e.flatten(new)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(after))
+	for i, v := range after {
+		index[v.path] = i
+	}
+
+	var changes []FieldChange
+	seen := make(map[string]struct{}, len(before))
+	for _, b := range before {
+		seen[b.path] = struct{}{}
+		i, ok := index[b.path]
+		if !ok {
+			if b.set {
+				changes = append(changes, b.change(ChangeRemoved, b, flatValue{}))
+			}
+			continue
+		}
+		a := after[i]
+		switch {
+		case b.set && !a.set:
+			changes = append(changes, b.change(ChangeRemoved, b, a))
+		case !b.set && a.set:
+			changes = append(changes, b.change(ChangeAdded, b, a))
+		case b.value != a.value:
+			changes = append(changes, b.change(ChangeModified, b, a))
+		}
+	}
+	for _, a := range after {
+		if _, ok := seen[a.path]; !ok && a.set {
+			changes = append(changes, a.change(ChangeAdded, flatValue{}, a))
+		}
+	}
+	return changes, nil
+}
+
+// Fingerprint is like Enviro.Fingerprint with a default Enviro instance.
+func Fingerprint(config any, key []byte) (string, error) {
+	return New().Fingerprint(config, key)
+}
+
+// Fingerprint returns an HMAC-SHA256, in hexadecimal, of the effective configuration, keyed by key. It only
+// depends on the environment variables and formatted values of the configuration, so replicas running with the
+// same configuration and key have the same fingerprint, which makes drift easy to detect. Secret values are part
+// of the hash, so that rotating a secret changes the fingerprint.
+//
+// The key keeps secrets safe when the fingerprint is published, e.g. in logs or metrics: without it, anyone could
+// hash candidate values and recover a low-entropy secret offline. The tradeoff is that fingerprints can only be
+// compared between replicas sharing the same key, which should be kept as secret as the configuration itself.
+// Values that are excluded from Diff, such as a *x509.CertPool, are excluded from the fingerprint.
+func (e *Enviro) Fingerprint(config any, key []byte) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("empty fingerprint key")
+	}
+	values, err := e.flatten(config)
+	if err != nil {
+		return "", err
+	}
+	h := hmac.New(sha256.New, key)
+	for _, v := range values {
+		if v.set {
+			fmt.Fprintf(h, "%s=%q\n", v.path, v.value)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// flatValue is the formatted value of a field, or of an element of a slice, an array or a map.
+type flatValue struct {
+	key    string
+	path   string
+	field  string
+	value  string
+	set    bool
+	secret bool
+}
+
+func (v flatValue) change(kind ChangeKind, old, new flatValue) FieldChange {
+	return FieldChange{
+		Key:   v.key,
+		Path:  v.path,
+		Field: v.field,
+		Kind:  kind,
+		Old:   old.display(),
+		New:   new.display(),
+	}
+}
+
+// display returns the value, redacted for secrets.
+func (v flatValue) display() string {
+	if v.secret && v.set && v.value != "" {
+		return redacted
+	}
+	return v.value
+}
+
+// flatten formats every field of config, splitting slices, arrays and maps into their elements.
+func (e *Enviro) flatten(config any) ([]flatValue, error) {
+	var values []flatValue
+	err := walkFields(config, e.prefix, func(bf boundField) error {
+		base := flatValue{key: bf.key, path: bf.key, field: bf.name, secret: bf.secret}
+		return e.flattenValue(&values, base, bf.value, bf.opts)
+	})
+	return values, err
+}
+
+func (e *Enviro) flattenValue(values *[]flatValue, v flatValue, field reflect.Value, opts Options) error {
+	field = unwrapDynamic(field)
+	for field.Kind() == reflect.Ptr && !field.IsNil() && !e.hasFormatter(field.Type()) {
+		field = field.Elem()
+	}
+
+	if !e.hasFormatter(field.Type()) && !isEnumType(field.Type(), opts) {
+		switch field.Kind() {
+		case reflect.Slice, reflect.Array:
+			if e.isRawBytes(field.Type().Elem(), opts) {
+				break
+			}
+			elemOpts := opts.nested()
+			for i := 0; i < field.Len(); i++ {
+				elem := v
+				elem.path = v.path + "[" + strconv.Itoa(i) + "]"
+				if err := e.flattenValue(values, elem, field.Index(i), elemOpts); err != nil {
+					return err
+				}
+			}
+			return nil
+		case reflect.Map:
+			if opts.Has("json") || opts.Has("yaml") || field.IsNil() {
+				break
+			}
+			keyOpts, elemOpts := opts.mapKey(), opts.nested()
+			type entry struct {
+				key   string
+				value reflect.Value
+			}
+			var entries []entry
+			iter := field.MapRange()
+			for iter.Next() {
+				k, err := e.formatField(iter.Key(), keyOpts)
+				if err != nil {
+					return fmt.Errorf("invalid key %v of %s: %w", iter.Key().Interface(), v.path, err)
+				}
+				entries = append(entries, entry{key: k, value: iter.Value()})
+			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+			for _, en := range entries {
+				elem := v
+				elem.path = v.path + "[" + en.key + "]"
+				if err := e.flattenValue(values, elem, en.value, elemOpts); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	// A certificate pool can't be formatted, and its content can't be compared reliably
+	if field.Type() == certPoolType {
+		return nil
+	}
+
+	value, err := e.formatField(field, opts)
+	if err != nil && pemMode(opts) == "file" {
+		// The path of a PEM file can't be recovered, so its content is compared instead
+		value, err = e.formatField(field, opts.without("pem"))
+	}
+	switch {
+	case errors.Is(err, errNoFormatter):
+		// A type with a registered parser but no formatter has no canonical form to compare, so it is left out
+		// rather than failing the whole diff
+		return nil
+	case errors.Is(err, errUnset):
+	case err != nil:
+		return fmt.Errorf("failed to format %s: %w", v.path, err)
+	default:
+		v.value, v.set = value, true
+	}
+	*values = append(*values, v)
+	return nil
+}
+
+var certPoolType = reflect.TypeOf((*x509.CertPool)(nil))
+
+// hasFormatter reports whether typ is formatted as a whole, rather than element by element.
+func (e *Enviro) hasFormatter(typ reflect.Type) bool {
+	if _, ok := e.lookupFormatter(typ); ok {
+		return true
+	}
+	if _, ok := builtinFormatters[typ]; ok {
+		return true
+	}
+	ptr := reflect.PointerTo(typ)
+	return ptr.Implements(formatterType) || ptr.Implements(textMarshalerType) || ptr.Implements(flagValueType)
+}
+
+func isEnumType(typ reflect.Type, opts Options) bool {
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return false
+	}
+	table, _ := lookupEnumTable(typ, opts)
+	return table != nil
+}
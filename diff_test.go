@@ -0,0 +1,296 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	type Proxy struct {
+		URL     *url.URL      `enviro:"url"`
+		Timeout time.Duration `enviro:"timeout"`
+	}
+
+	type Config struct {
+		Hosts    []string          `enviro:"hosts"`
+		Limits   map[string]int    `enviro:"limits"`
+		Password string            `enviro:"password,secret"`
+		Tokens   []string          `enviro:"tokens,secret"`
+		Settings map[string]string `enviro:"settings" envopt:"json"`
+		Retries  *int              `enviro:"retries"`
+		Level    Dynamic[string]   `enviro:"level"`
+		Proxy    Proxy             `enviro:"nested:proxy"`
+	}
+
+	retries := 3
+	old := &Config{
+		Hosts:    []string{"a", "b", "c"},
+		Limits:   map[string]int{"read": 10, "write": 5},
+		Password: "s3cr3t",
+		Tokens:   []string{"t1"},
+		Settings: map[string]string{"a": "1"},
+		Retries:  &retries,
+		Proxy:    Proxy{URL: &url.URL{Scheme: "http", Host: "proxy"}, Timeout: time.Second},
+	}
+	old.Level.set("info")
+	new := &Config{
+		Hosts:    []string{"a", "x"},
+		Limits:   map[string]int{"read": 20, "delete": 1, "write": 5},
+		Password: "changed",
+		Tokens:   []string{"t1"},
+		Settings: map[string]string{"a": "2"},
+		Proxy:    Proxy{URL: &url.URL{Scheme: "http", Host: "proxy"}, Timeout: 2 * time.Second},
+	}
+	new.Level.set("debug")
+
+	e := New()
+	e.SetEnvPrefix("APP")
+	changes, err := e.Diff(old, new)
+	if err != nil {
+		t.Fatalf("Failed to diff configs: %s", err)
+	}
+
+	expected := []FieldChange{
+		{Key: "APP_HOSTS", Path: "APP_HOSTS[1]", Field: "Hosts", Kind: ChangeModified, Old: "b", New: "x"},
+		{Key: "APP_HOSTS", Path: "APP_HOSTS[2]", Field: "Hosts", Kind: ChangeRemoved, Old: "c"},
+		{Key: "APP_LIMITS", Path: "APP_LIMITS[read]", Field: "Limits", Kind: ChangeModified, Old: "10", New: "20"},
+		{Key: "APP_PASSWORD", Path: "APP_PASSWORD", Field: "Password", Kind: ChangeModified, Old: redacted, New: redacted},
+		{Key: "APP_SETTINGS", Path: "APP_SETTINGS", Field: "Settings", Kind: ChangeModified, Old: `{"a":"1"}`, New: `{"a":"2"}`},
+		{Key: "APP_RETRIES", Path: "APP_RETRIES", Field: "Retries", Kind: ChangeRemoved, Old: "3"},
+		{Key: "APP_LEVEL", Path: "APP_LEVEL", Field: "Level", Kind: ChangeModified, Old: "info", New: "debug"},
+		{Key: "APP_PROXY_TIMEOUT", Path: "APP_PROXY_TIMEOUT", Field: "Proxy.Timeout", Kind: ChangeModified, Old: "1s", New: "2s"},
+		{Key: "APP_LIMITS", Path: "APP_LIMITS[delete]", Field: "Limits", Kind: ChangeAdded, New: "1"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+
+	if changes, err := e.Diff(old, old); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no change, got %+v (%v)", changes, err)
+	}
+	if _, err := Diff(old, Proxy{}); err == nil {
+		t.Errorf("Expected an error for configs of different types")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	type Config struct {
+		Hosts    []string       `enviro:"hosts"`
+		Limits   map[string]int `enviro:"limits"`
+		Password string         `enviro:"password,secret"`
+	}
+
+	a := &Config{Hosts: []string{"a", "b"}, Limits: map[string]int{"x": 1, "y": 2, "z": 3}, Password: "p"}
+	b := &Config{Hosts: []string{"a", "b"}, Limits: map[string]int{"z": 3, "y": 2, "x": 1}, Password: "p"}
+
+	key := []byte("fingerprint-key")
+	fa, err := Fingerprint(a, key)
+	if err != nil {
+		t.Fatalf("Failed to fingerprint config: %s", err)
+	}
+	fb, _ := Fingerprint(b, key)
+	if fa != fb || len(fa) != 64 {
+		t.Errorf("Expected identical fingerprints, got %s and %s", fa, fb)
+	}
+
+	if fc, _ := Fingerprint(a, []byte("other-key")); fa == fc {
+		t.Errorf("Expected a different fingerprint with another key")
+	}
+	if _, err := Fingerprint(a, nil); err == nil {
+		t.Errorf("Expected an error for an empty key")
+	}
+
+	b.Password = "rotated"
+	if fb, _ := Fingerprint(b, key); fa == fb {
+		t.Errorf("Expected a different fingerprint after rotating a secret")
+	}
+}
+
+func TestDiffCrypto(t *testing.T) {
+	type Config struct {
+		Cert *x509.Certificate `enviro:"cert" envopt:"pem:file"`
+		Pool *x509.CertPool    `enviro:"pool" envopt:"pem:auto"`
+	}
+
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	certPEM, _ := newTestCertificate(t)
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("CERT", certFile)
+	os.Setenv("POOL", string(certPEM))
+	defer os.Unsetenv("CERT")
+	defer os.Unsetenv("POOL")
+
+	e := New()
+	var a, b Config
+	if err := e.ParseEnv(&a); err != nil {
+		t.Fatalf("Failed to parse config: %s", err)
+	}
+	if err := e.ParseEnv(&b); err != nil {
+		t.Fatalf("Failed to parse config: %s", err)
+	}
+
+	// The certificate pools are distinct pointers, they must not make the configs differ
+	if changes, err := e.Diff(&a, &b); err != nil || len(changes) != 0 {
+		t.Errorf("Expected no change, got %+v (%v)", changes, err)
+	}
+	key := []byte("fingerprint-key")
+	fa, err := e.Fingerprint(&a, key)
+	if err != nil {
+		t.Fatalf("Failed to fingerprint config: %s", err)
+	}
+	if fb, _ := e.Fingerprint(&b, key); fa != fb {
+		t.Errorf("Expected identical fingerprints, got %s and %s", fa, fb)
+	}
+
+	certPEM, _ = newTestCertificate(t)
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ParseEnv(&b); err != nil {
+		t.Fatalf("Failed to parse config: %s", err)
+	}
+	changes, err := e.Diff(&a, &b)
+	if err != nil || len(changes) != 1 || changes[0].Path != "CERT" || changes[0].Kind != ChangeModified {
+		t.Errorf("Expected the certificate to be modified, got %+v (%v)", changes, err)
+	}
+}
+
+func TestDiffSecretTypes(t *testing.T) {
+	type Config struct {
+		Keys    []crypto.PrivateKey      `enviro:"keys"`
+		Current Dynamic[crypto.Signer]   `enviro:"current"`
+		Pairs   [1]tls.Certificate       `enviro:"pairs"`
+		Signers map[string]crypto.Signer `enviro:"signers"`
+	}
+
+	newKey := func() *ecdsa.PrivateKey {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	old, new := &Config{Keys: []crypto.PrivateKey{newKey()}}, &Config{Keys: []crypto.PrivateKey{newKey()}}
+	old.Current.set(newKey())
+	new.Current.set(newKey())
+	changes, err := Diff(old, new)
+	if err != nil {
+		t.Fatalf("Failed to diff configs: %s", err)
+	}
+	expected := []FieldChange{
+		{Key: "KEYS", Path: "KEYS[0]", Field: "Keys", Kind: ChangeModified, Old: redacted, New: redacted},
+		{Key: "CURRENT", Path: "CURRENT", Field: "Current", Kind: ChangeModified, Old: redacted, New: redacted},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf(Config{}.Pairs),
+		reflect.TypeOf(Config{}.Signers),
+	} {
+		if !isSecretType(typ) {
+			t.Errorf("Expected %s to be a secret type", typ)
+		}
+	}
+}
+
+func TestDiffUnformattable(t *testing.T) {
+	type point struct{ X, Y int }
+	type Config struct {
+		Origin point  `enviro:"origin"`
+		Name   string `enviro:"name"`
+	}
+
+	e := New()
+	RegisterInstanceParser(e, func(value string) (point, error) {
+		return point{}, nil
+	})
+
+	// A type without a formatter is excluded, the other fields are still compared
+	changes, err := e.Diff(&Config{Origin: point{1, 2}, Name: "a"}, &Config{Name: "b"})
+	expected := []FieldChange{{Key: "NAME", Path: "NAME", Field: "Name", Kind: ChangeModified, Old: "a", New: "b"}}
+	if err != nil || !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v (%v)", expected, changes, err)
+	}
+	key := []byte("fingerprint-key")
+	fa, err := e.Fingerprint(&Config{Origin: point{1, 2}}, key)
+	if err != nil {
+		t.Fatalf("Failed to fingerprint config: %s", err)
+	}
+	if fb, _ := e.Fingerprint(&Config{}, key); fa != fb {
+		t.Errorf("Expected identical fingerprints, got %s and %s", fa, fb)
+	}
+
+	// With a formatter, the values are compared
+	RegisterInstanceFormatter(e, func(value point) (string, error) {
+		return strconv.Itoa(value.X) + ":" + strconv.Itoa(value.Y), nil
+	})
+	changes, err = e.Diff(&Config{Origin: point{1, 2}}, &Config{})
+	if err != nil || len(changes) != 1 || changes[0].Old != "1:2" || changes[0].New != "0:0" {
+		t.Errorf("Expected the origin to be modified, got %+v (%v)", changes, err)
+	}
+}
+
+func TestDiffErrorPaths(t *testing.T) {
+	type Config struct {
+		Hosts  []int          `enviro:"hosts"`
+		Limits map[string]int `enviro:"limits"`
+		Matrix [][]int        `enviro:"matrix" envopt:"sep:;|,"`
+	}
+
+	cases := []struct {
+		key   string
+		value string
+		path  string
+	}{
+		{key: "HOSTS", value: "1,2,x", path: "HOSTS[2]"},
+		{key: "LIMITS", value: "read=1,write=x", path: "LIMITS[write]"},
+		{key: "MATRIX", value: "1,2;3,x", path: "MATRIX[1][1]"},
+	}
+
+	for _, tc := range cases {
+		os.Setenv(tc.key, tc.value)
+		err := New().ParseEnv(&Config{})
+		os.Unsetenv(tc.key)
+		want := "failed to parse environment variable " + tc.path + ": "
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("Expected error starting with %q, got %v", want, err)
+		}
+	}
+
+	changes, err := Diff(
+		&Config{Hosts: []int{1, 2, 3}, Limits: map[string]int{"write": 1}, Matrix: [][]int{{1, 2}, {3, 4}}},
+		&Config{Hosts: []int{1, 2, 4}, Limits: map[string]int{"write": 2}, Matrix: [][]int{{1, 2}, {3, 5}}},
+	)
+	if err != nil {
+		t.Fatalf("Failed to diff configs: %s", err)
+	}
+	var paths []string
+	for _, c := range changes {
+		paths = append(paths, c.Path)
+	}
+	expected := []string{"HOSTS[2]", "LIMITS[write]", "MATRIX[1][1]"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, paths)
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DotEnv is the content of a .env file, made of KEY=VALUE assignments, comments and blank lines. It can be
+// edited and written back while preserving the comments, blank lines, order and quoting style of the original
+// file; only the assignments that are changed are rewritten.
+//
+// Values may be unquoted, single quoted (taken literally) or double quoted (with the \n, \t, \r, \", \\ and \$
+// escapes), and quoted values may span several lines. Unquoted values end at a " #" inline comment. Lines may
+// start with "export ". Variable references such as ${HOME} are not expanded.
+type DotEnv struct {
+	lines   []*dotEnvLine
+	trailer bool
+}
+
+// dotEnvLine is a line of a .env file, or several lines for a multi-line value. For an assignment, head holds
+// the text up to the value (e.g. "export KEY=") and suffix the text after it (e.g. " # comment"). The line ending,
+// "\n" or "\r\n", is kept apart from raw so that each line is written back with its own.
+type dotEnvLine struct {
+	raw    string
+	eol    string
+	key    string
+	value  string
+	head   string
+	quote  byte
+	suffix string
+	dirty  bool
+}
+
+// NewDotEnv returns an empty .env file.
+func NewDotEnv() *DotEnv {
+	return &DotEnv{trailer: true}
+}
+
+// LoadDotEnv reads and parses the .env file with the given name.
+func LoadDotEnv(name string) (*DotEnv, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadDotEnv(f)
+}
+
+// ReadDotEnv parses a .env file from r.
+func ReadDotEnv(r io.Reader) (*DotEnv, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+
+	d := &DotEnv{trailer: content == "" || strings.HasSuffix(content, "\n")}
+	lineNo := 1
+	for rest := content; rest != ""; {
+		line, err := parseDotEnvLine(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		d.lines = append(d.lines, line)
+		lineNo += strings.Count(line.raw, "\n") + 1
+		rest = rest[len(line.raw)+len(line.eol):]
+	}
+	return d, nil
+}
+
+// cutLine returns the line at the start of s, without its line ending, and the line ending, which is empty for
+// the last line of a file that doesn't end with a new line.
+func cutLine(s string) (line, eol string) {
+	end := strings.IndexByte(s, '\n')
+	if end < 0 {
+		return s, ""
+	}
+	if end > 0 && s[end-1] == '\r' {
+		return s[:end-1], "\r\n"
+	}
+	return s[:end], "\n"
+}
+
+// parseDotEnvLine parses the line at the start of s, which spans several lines for a multi-line quoted value.
+func parseDotEnvLine(s string) (*dotEnvLine, error) {
+	line, eol := cutLine(s)
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return &dotEnvLine{raw: line, eol: eol}, nil
+	}
+
+	rest := strings.TrimLeft(line, " \t")
+	if after, ok := strings.CutPrefix(rest, "export"); ok && strings.TrimLeft(after, " \t") != after {
+		rest = strings.TrimLeft(after, " \t")
+	}
+	eq := strings.IndexByte(rest, '=')
+	if eq < 0 {
+		return nil, fmt.Errorf("invalid assignment %q: missing '='", trimmed)
+	}
+	key := strings.TrimSpace(rest[:eq])
+	if !isDotEnvKey(key) {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	value := strings.TrimLeft(rest[eq+1:], " \t")
+	start := len(line) - len(value)
+	l := &dotEnvLine{key: key, head: line[:start]}
+
+	if value == "" || (value[0] != '"' && value[0] != '\'') {
+		// An unquoted value ends at an inline comment, which must be preceded by a space
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = value[:i]
+		} else if i := strings.Index(value, "\t#"); i >= 0 {
+			value = value[:i]
+		} else if strings.HasPrefix(value, "#") {
+			value = ""
+		}
+		l.value = strings.TrimRight(value, " \t")
+		l.suffix = line[start+len(l.value):]
+		l.raw, l.eol = line, eol
+		return l, nil
+	}
+
+	// A quoted value ends at the matching quote, possibly on another line
+	l.quote = value[0]
+	var sb strings.Builder
+	i := start + 1
+	for ; i < len(s); i++ {
+		c := s[i]
+		if c == l.quote {
+			break
+		}
+		if c == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+			// A multi-line value uses new lines, whatever the line endings of the file
+			continue
+		}
+		if c == '\\' && l.quote == '"' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case '"', '\\', '$':
+				sb.WriteByte(s[i])
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(s[i])
+			}
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	if i == len(s) {
+		return nil, fmt.Errorf("unterminated quoted value for key %s", key)
+	}
+
+	last, eol := cutLine(s[i:])
+	l.value = sb.String()
+	l.suffix = last[1:]
+	if rest := strings.TrimSpace(l.suffix); rest != "" && !strings.HasPrefix(rest, "#") {
+		return nil, fmt.Errorf("unexpected %q after quoted value for key %s", rest, key)
+	}
+	l.raw, l.eol = s[:i+len(last)], eol
+	return l, nil
+}
+
+func isDotEnvKey(key string) bool {
+	if key == "" || (key[0] >= '0' && key[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the value of the last assignment of key and reports whether it was found.
+func (d *DotEnv) Get(key string) (string, bool) {
+	if l := d.lookup(key); l != nil {
+		return l.value, true
+	}
+	return "", false
+}
+
+// Set sets the value of key. An existing assignment is updated in place, keeping its quoting style unless the
+// value requires double quotes, and its inline comment. A new key is appended at the end of the file.
+func (d *DotEnv) Set(key, value string) error {
+	if !isDotEnvKey(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+	if l := d.lookup(key); l != nil {
+		l.value = value
+		l.dirty = true
+		return nil
+	}
+	d.lines = append(d.lines, &dotEnvLine{key: key, value: value, head: key + "=", dirty: true})
+	return nil
+}
+
+// Unset removes every assignment of key and reports whether there was any.
+func (d *DotEnv) Unset(key string) bool {
+	var found bool
+	lines := d.lines[:0]
+	for _, l := range d.lines {
+		if l.key == key {
+			found = true
+			continue
+		}
+		lines = append(lines, l)
+	}
+	d.lines = lines
+	return found
+}
+
+// Keys returns the keys assigned in the file, in order of first appearance.
+func (d *DotEnv) Keys() []string {
+	var keys []string
+	seen := make(map[string]struct{})
+	for _, l := range d.lines {
+		if _, ok := seen[l.key]; l.key != "" && !ok {
+			seen[l.key] = struct{}{}
+			keys = append(keys, l.key)
+		}
+	}
+	return keys
+}
+
+// Environ returns the assignments in the KEY=VALUE form of os.Environ, the last assignment of a key winning.
+func (d *DotEnv) Environ() []string {
+	var env []string
+	for _, key := range d.Keys() {
+		value, _ := d.Get(key)
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+func (d *DotEnv) lookup(key string) *dotEnvLine {
+	for i := len(d.lines) - 1; i >= 0; i-- {
+		if d.lines[i].key == key {
+			return d.lines[i]
+		}
+	}
+	return nil
+}
+
+// WriteTo writes the file to w. Lines that were not changed are written as they were read.
+func (d *DotEnv) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	for i, l := range d.lines {
+		text := l.raw
+		if l.dirty {
+			text = l.head + quoteDotEnvValue(l.value, l.quote) + l.suffix
+		}
+		if i < len(d.lines)-1 || d.trailer {
+			eol := l.eol
+			if eol == "" {
+				eol = d.newline()
+			}
+			text += eol
+		}
+		m, err := bw.WriteString(text)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// newline returns the line ending of new lines, the one of the first line of the file if any.
+func (d *DotEnv) newline() string {
+	if len(d.lines) > 0 && d.lines[0].eol != "" {
+		return d.lines[0].eol
+	}
+	return "\n"
+}
+
+// Save writes the file to the given name. The file is replaced atomically, keeping the permissions of an
+// existing file, or 0600 for a new one since .env files usually hold secrets.
+func (d *DotEnv) Save(name string) error {
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := d.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// quoteDotEnvValue quotes a value with the given quote, or without quotes if quote is zero, falling back to
+// double quotes when the value can't be represented otherwise.
+func quoteDotEnvValue(value string, quote byte) string {
+	switch quote {
+	case 0:
+		if !needsDotEnvQuotes(value) {
+			return value
+		}
+	case '\'':
+		if !strings.ContainsAny(value, "'\n\r") {
+			return "'" + value + "'"
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '"', '\\', '$':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func needsDotEnvQuotes(value string) bool {
+	// Spaces and dollar signs are allowed in unquoted values, but quoting them keeps the file compatible with shells
+	// and docker compose, which would split the value or expand variable references
+	return strings.ContainsAny(value, " \n\r\t\"'\\#$")
+}
+
+// WriteDotEnv renders config as a fresh .env file, with the environment variables that ParseEnv would parse into
+// config, in the format of Marshal. Each variable is preceded by its description from the `envdesc` tag, its
+// default value and whether it is required. Variables without a value, such as nil pointers, are written
+// commented out.
+func (e *Enviro) WriteDotEnv(w io.Writer, config any) error {
+	d := NewDotEnv()
+	err := walkFields(config, e.prefix, func(bf boundField) error {
+		value, err := e.formatField(bf.value, bf.opts)
+		unset := errors.Is(err, errUnset)
+		if err != nil && !unset {
+			return variableError("marshal", bf.key, err)
+		}
+
+		if len(d.lines) > 0 {
+			d.lines = append(d.lines, &dotEnvLine{})
+		}
+		if desc := bf.field.Tag.Get("envdesc"); desc != "" {
+			for _, line := range strings.Split(desc, "\n") {
+				d.lines = append(d.lines, &dotEnvLine{raw: strings.TrimRight("# "+strings.TrimSpace(line), " ")})
+			}
+		}
+		var notes []string
+		if bf.required {
+			notes = append(notes, "Required.")
+		}
+		if bf.def != "" {
+			notes = append(notes, "Default: "+bf.def)
+		}
+		if len(notes) > 0 {
+			d.lines = append(d.lines, &dotEnvLine{raw: "# " + strings.Join(notes, " ")})
+		}
+
+		if unset {
+			d.lines = append(d.lines, &dotEnvLine{raw: "# " + bf.key + "=" + quoteDotEnvValue(bf.def, 0)})
+			return nil
+		}
+		d.lines = append(d.lines, &dotEnvLine{key: bf.key, value: value, head: bf.key + "=", dirty: true})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = d.WriteTo(w)
+	return err
+}
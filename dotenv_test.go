@@ -0,0 +1,206 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDotEnv(t *testing.T) {
+	const content = `# Database settings
+export DB_HOST = localhost # primary
+DB_PASSWORD='s3cr3t'
+
+TOKEN="abc\"def" # rotated weekly
+CERT="-----BEGIN CERTIFICATE-----
+MIIB
+-----END CERTIFICATE-----"
+EMPTY=
+`
+
+	d, err := ReadDotEnv(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to read .env file: %s", err)
+	}
+
+	expected := []string{
+		"DB_HOST=localhost",
+		"DB_PASSWORD=s3cr3t",
+		`TOKEN=abc"def`,
+		"CERT=-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----",
+		"EMPTY=",
+	}
+	if env := d.Environ(); !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected %q, got %q", expected, env)
+	}
+
+	var sb strings.Builder
+	if _, err := d.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != content {
+		t.Errorf("Expected an unchanged file, got:\n%s", sb.String())
+	}
+
+	if err := d.Set("DB_HOST", "db.internal"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("DB_PASSWORD", "it's"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("TOKEN", "xyz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("LOG_LEVEL", "debug # verbose"); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Unset("CERT") || d.Unset("CERT") {
+		t.Errorf("Expected CERT to be unset once")
+	}
+	if err := d.Set("1KEY", "x"); err == nil {
+		t.Errorf("Expected an error for an invalid key")
+	}
+
+	const updated = `# Database settings
+export DB_HOST = db.internal # primary
+DB_PASSWORD="it's"
+
+TOKEN="xyz" # rotated weekly
+EMPTY=
+LOG_LEVEL="debug # verbose"
+`
+	sb.Reset()
+	if _, err := d.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != updated {
+		t.Errorf("Expected:\n%s\ngot:\n%s", updated, sb.String())
+	}
+
+	for _, invalid := range []string{"KEY", "KEY=\"unterminated", "KEY='a' b", "-KEY=a"} {
+		if _, err := ReadDotEnv(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestDotEnvLineEndings(t *testing.T) {
+	const content = "# comment\r\nA=1\r\nB=\"x\r\ny\"\nC=3\r\n"
+
+	d, err := ReadDotEnv(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to read .env file: %s", err)
+	}
+	expected := []string{"A=1", "B=x\ny", "C=3"}
+	if env := d.Environ(); !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected %q, got %q", expected, env)
+	}
+
+	if err := d.Set("C", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("D", "$HOME"); err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if _, err := d.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := "# comment\r\nA=1\r\nB=\"x\r\ny\"\nC=\"pa\\$\\$word\"\r\nD=\"\\$HOME\"\r\n"
+	if sb.String() != want {
+		t.Errorf("Expected %q, got %q", want, sb.String())
+	}
+
+	d, err = ReadDotEnv(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("Failed to read .env file: %s", err)
+	}
+	expected = []string{"A=1", "B=x\ny", "C=pa$$word", "D=$HOME"}
+	if env := d.Environ(); !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected %q, got %q", expected, env)
+	}
+}
+
+func TestDotEnvSave(t *testing.T) {
+	name := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(name, []byte("A=1\nB=2"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := LoadDotEnv(name)
+	if err != nil {
+		t.Fatalf("Failed to load .env file: %s", err)
+	}
+	if err := d.Set("B", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Save(name); err != nil {
+		t.Fatalf("Failed to save .env file: %s", err)
+	}
+
+	data, _ := os.ReadFile(name)
+	if string(data) != "A=1\nB=3" {
+		t.Errorf("Expected %q, got %q", "A=1\nB=3", data)
+	}
+	if info, _ := os.Stat(name); info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %s", info.Mode().Perm())
+	}
+}
+
+func TestWriteDotEnv(t *testing.T) {
+	type Config struct {
+		Port    int           `enviro:"port" envdefault:"8080" envdesc:"Port to listen on."`
+		Host    string        `enviro:"host,required" envdesc:"Public host name,\nused in links."`
+		Timeout time.Duration `enviro:"timeout"`
+		Retries *int          `enviro:"retries" envdefault:"3"`
+		Proxy   struct {
+			URL string `enviro:"url" envdesc:"Proxy URL."`
+		} `enviro:"nested:proxy"`
+	}
+
+	config := Config{Port: 9000, Host: "my app", Timeout: 5 * time.Second}
+	config.Proxy.URL = "http://proxy:3128"
+
+	e := New()
+	e.SetEnvPrefix("APP")
+	var sb strings.Builder
+	if err := e.WriteDotEnv(&sb, &config); err != nil {
+		t.Fatalf("Failed to write .env file: %s", err)
+	}
+
+	const expected = `# Port to listen on.
+# Default: 8080
+APP_PORT=9000
+
+# Public host name,
+# used in links.
+# Required.
+APP_HOST="my app"
+
+APP_TIMEOUT=5s
+
+# Default: 3
+# APP_RETRIES=3
+
+# Proxy URL.
+APP_PROXY_URL=http://proxy:3128
+`
+	if sb.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+
+	d, err := ReadDotEnv(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("Failed to read rendered .env file: %s", err)
+	}
+	if host, _ := d.Get("APP_HOST"); host != "my app" {
+		t.Errorf("Expected %q, got %q", "my app", host)
+	}
+}
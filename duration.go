@@ -0,0 +1,169 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+var (
+	bareNumberRegexp  = regexp.MustCompile(`^[+-]?[0-9]+(?:\.[0-9]+)?$`)
+	dayWeekRegexp     = regexp.MustCompile(`([0-9]*\.?[0-9]+)([dw])`)
+	isoDurationRegexp = regexp.MustCompile(`^([+-])?P(?:([0-9.,]+)Y)?(?:([0-9.,]+)M)?(?:([0-9.,]+)W)?(?:([0-9.,]+)D)?(?:T(?:([0-9.,]+)H)?(?:([0-9.,]+)M)?(?:([0-9.,]+)S)?)?$`)
+)
+
+// durationUnits maps the units accepted by the `unit` option to the suffix appended to bare numbers.
+var durationUnits = map[string]string{
+	"ns": "ns",
+	"us": "us",
+	"µs": "µs",
+	"ms": "ms",
+	"s":  "s",
+	"m":  "m",
+	"h":  "h",
+	"d":  "d",
+	"w":  "w",
+}
+
+// setDuration parses a time.Duration. Besides the time.ParseDuration syntax, the `duration` option enables
+// extended formats separated by "|": "extended" accepts day (d) and week (w) units, e.g. "7d" or "1w2d12h",
+// and "iso8601" accepts ISO 8601 durations such as "P1DT2H" (years and months, whose length varies, are
+// rejected). The `unit` option interprets bare numbers in the given unit, e.g. `envopt:"unit:s"` parses "30"
+// as 30 seconds, while values with a unit are parsed as usual.
+func setDuration(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	var extended, iso bool
+	if formats, ok := opts.Lookup("duration"); ok {
+		for _, format := range strings.Split(formats, "|") {
+			switch strings.TrimSpace(format) {
+			case "extended":
+				extended = true
+			case "iso8601":
+				iso = true
+			default:
+				return fmt.Errorf("unsupported duration format %q", format)
+			}
+		}
+	}
+
+	if unit, ok := opts.Lookup("unit"); ok {
+		suffix, ok := durationUnits[unit]
+		if !ok {
+			return fmt.Errorf("unsupported duration unit %q", unit)
+		}
+		if bareNumberRegexp.MatchString(value) {
+			value += suffix
+		}
+		// Days and weeks as the unit of bare numbers imply the extended format
+		extended = extended || unit == "d" || unit == "w"
+	}
+
+	var d time.Duration
+	var err error
+	switch {
+	case iso && strings.HasPrefix(strings.TrimLeft(value, "+-"), "P"):
+		d, err = parseISODuration(value)
+	case extended:
+		d, err = parseExtendedDuration(value)
+	default:
+		d, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return err
+	}
+
+	field.SetInt(int64(d))
+	return nil
+}
+
+// parseExtendedDuration parses a duration that may use day (d) and week (w) units in addition to the ones
+// understood by time.ParseDuration. Days and weeks are converted to nanoseconds with exact arithmetic, so that a
+// fraction like "0.3w" does not suffer from floating point rounding.
+func parseExtendedDuration(value string) (time.Duration, error) {
+	var err error
+	converted := dayWeekRegexp.ReplaceAllStringFunc(value, func(s string) string {
+		m := dayWeekRegexp.FindStringSubmatch(s)
+		n, ok := new(big.Rat).SetString(m[1])
+		if !ok {
+			err = fmt.Errorf("invalid number %q", m[1])
+			return s
+		}
+		unit := day
+		if m[2] == "w" {
+			unit = week
+		}
+		n.Mul(n, new(big.Rat).SetInt64(int64(unit)))
+		// Fractions of a nanosecond are truncated
+		return new(big.Int).Quo(n.Num(), n.Denom()).String() + "ns"
+	})
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	d, err := time.ParseDuration(converted)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return d, nil
+}
+
+// parseISODuration parses an ISO 8601 duration such as "P1W", "PT1H30M" or "-P1DT0.5S".
+func parseISODuration(value string) (time.Duration, error) {
+	m := isoDurationRegexp.FindStringSubmatch(value)
+	if m == nil || strings.HasSuffix(value, "T") || strings.TrimLeft(value, "+-") == "P" {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", value)
+	}
+	if m[2] != "" || m[3] != "" {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q: years and months are not supported", value)
+	}
+
+	total := new(big.Rat)
+	for i, unit := range []time.Duration{week, day, time.Hour, time.Minute, time.Second} {
+		s := m[i+4]
+		if s == "" {
+			continue
+		}
+		n, ok := new(big.Rat).SetString(strings.Replace(s, ",", ".", 1))
+		if !ok {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q", value)
+		}
+		total.Add(total, n.Mul(n, new(big.Rat).SetInt64(int64(unit))))
+	}
+	if m[1] == "-" {
+		total.Neg(total)
+	}
+
+	// Fractions of a nanosecond are truncated
+	ns := new(big.Int).Quo(total.Num(), total.Denom())
+	if !ns.IsInt64() || ns.Int64() == math.MinInt64 {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q: overflow", value)
+	}
+	return time.Duration(ns.Int64()), nil
+}
+
+// formatDuration formats a duration as a bare number when the field has a `unit` option that divides it, and in
+// the Go syntax otherwise, which every duration format accepts.
+func formatDuration(_ *Enviro, field reflect.Value, opts Options) (string, error) {
+	d := time.Duration(field.Int())
+	if unit, ok := opts.Lookup("unit"); ok {
+		if suffix, ok := durationUnits[unit]; ok {
+			if u, err := parseExtendedDuration("1" + suffix); err == nil && d%u == 0 {
+				return strconv.FormatInt(int64(d/u), 10), nil
+			}
+		}
+	}
+	return d.String(), nil
+}
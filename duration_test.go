@@ -0,0 +1,131 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseISODuration(t *testing.T) {
+	cases := []struct {
+		value string
+		want  time.Duration
+		err   string
+	}{
+		{value: "P1D", want: 24 * time.Hour},
+		{value: "PT1H30M", want: 90 * time.Minute},
+		{value: "P1W", want: 7 * 24 * time.Hour},
+		{value: "P1DT2H", want: 26 * time.Hour},
+		{value: "-PT0,5S", want: -500 * time.Millisecond},
+		{value: "P1Y", err: "years and months are not supported"},
+		{value: "P", err: "invalid ISO 8601 duration"},
+		{value: "P1DT", err: "invalid ISO 8601 duration"},
+		{value: "PT1S1H", err: "invalid ISO 8601 duration"},
+		{value: "P999999W", err: "overflow"},
+	}
+
+	for _, tc := range cases {
+		d, err := parseISODuration(tc.value)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("parseISODuration(%q): expected error containing %q, got %v", tc.value, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseISODuration(%q): unexpected error: %s", tc.value, err)
+			continue
+		}
+		if d != tc.want {
+			t.Errorf("parseISODuration(%q): expected %s, got %s", tc.value, tc.want, d)
+		}
+	}
+}
+
+func TestParseExtendedDuration(t *testing.T) {
+	cases := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{value: "1w2d12h", want: 9*24*time.Hour + 12*time.Hour},
+		{value: "0.3w", want: 50*time.Hour + 24*time.Minute},
+		{value: "1.5d", want: 36 * time.Hour},
+		{value: "-2d30m", want: -(48*time.Hour + 30*time.Minute)},
+		{value: "1d1x", err: true},
+		{value: "999999999w", err: true},
+	}
+
+	for _, tc := range cases {
+		d, err := parseExtendedDuration(tc.value)
+		if tc.err {
+			if err == nil {
+				t.Errorf("parseExtendedDuration(%q): expected an error, got %s", tc.value, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseExtendedDuration(%q): unexpected error: %s", tc.value, err)
+			continue
+		}
+		if d != tc.want {
+			t.Errorf("parseExtendedDuration(%q): expected %s, got %s", tc.value, tc.want, d)
+		}
+	}
+}
+
+func TestParseEnvDuration(t *testing.T) {
+	type Config struct {
+		Retention time.Duration            `enviro:"retention" envopt:"duration:extended"`
+		Interval  *time.Duration           `enviro:"interval" envopt:"duration:iso8601|extended"`
+		Timeout   time.Duration            `enviro:"timeout" envopt:"unit:s"`
+		Backoff   []time.Duration          `enviro:"backoff" envopt:"unit:ms"`
+		Windows   map[string]time.Duration `enviro:"windows" envopt:"unit:d"`
+		Plain     time.Duration            `enviro:"plain"`
+	}
+
+	os.Setenv("RETENTION", "1w2d12h")
+	os.Setenv("INTERVAL", "PT15M")
+	os.Setenv("TIMEOUT", "30")
+	os.Setenv("BACKOFF", "100, 250, 1s")
+	os.Setenv("WINDOWS", "short=1, long=2w")
+	os.Setenv("PLAIN", "1h")
+	defer func() {
+		os.Unsetenv("RETENTION")
+		os.Unsetenv("INTERVAL")
+		os.Unsetenv("TIMEOUT")
+		os.Unsetenv("BACKOFF")
+		os.Unsetenv("WINDOWS")
+		os.Unsetenv("PLAIN")
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse duration environment variables: %s", err)
+	}
+
+	interval := 15 * time.Minute
+	expected := Config{
+		Retention: 9*24*time.Hour + 12*time.Hour,
+		Interval:  &interval,
+		Timeout:   30 * time.Second,
+		Backoff:   []time.Duration{100 * time.Millisecond, 250 * time.Millisecond, time.Second},
+		Windows:   map[string]time.Duration{"short": 24 * time.Hour, "long": 14 * 24 * time.Hour},
+		Plain:     time.Hour,
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	os.Setenv("PLAIN", "7d")
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected days to be rejected without the extended format")
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrRestartRequired is returned by Reload when a static field changed, which only takes effect after a restart.
+var ErrRestartRequired = errors.New("restart required")
+
+// Dynamic is a field that can be updated while the program runs, such as a log level or a rate limit. ParseEnv
+// parses it like a field of type T, with the same options, and Reload updates it in place. A Watcher carries the
+// Dynamic fields of its current configuration over to each reloaded one: the fields of the old and new
+// configurations share their value and OnChange callbacks, which are called when the reload changes the value.
+// Dynamic is safe for concurrent use and must not be copied after first use, so the configuration holding it
+// should be passed by pointer.
+//
+//	type Config struct {
+//		Timeout enviro.Dynamic[time.Duration] `enviro:"timeout" envdefault:"5s"`
+//	}
+type Dynamic[T any] struct {
+	s atomic.Pointer[dynamicState[T]]
+}
+
+// dynamicState is the value and callbacks of a Dynamic, which a Watcher shares between the Dynamic fields of
+// successive configurations.
+type dynamicState[T any] struct {
+	v    atomic.Pointer[T]
+	mu   sync.Mutex
+	subs []dynamicSubscriber[T]
+	next int
+}
+
+type dynamicSubscriber[T any] struct {
+	id int
+	fn func(old, new T)
+}
+
+func (d *Dynamic[T]) state() *dynamicState[T] {
+	if s := d.s.Load(); s != nil {
+		return s
+	}
+	d.s.CompareAndSwap(nil, new(dynamicState[T]))
+	return d.s.Load()
+}
+
+// Load returns the current value, or the zero value of T if it was never set.
+func (d *Dynamic[T]) Load() T {
+	if p := d.state().v.Load(); p != nil {
+		return *p
+	}
+	var zero T
+	return zero
+}
+
+// OnChange registers fn to be called with the old and new values each time a reload changes the value. It is
+// called from the goroutine that reloaded the configuration, after the callbacks registered before it. The
+// returned function removes the callback.
+func (d *Dynamic[T]) OnChange(fn func(old, new T)) (unsubscribe func()) {
+	s := d.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.next
+	s.next++
+	s.subs = append(s.subs, dynamicSubscriber[T]{id: id, fn: fn})
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, sub := range s.subs {
+			if sub.id == id {
+				s.subs = append(s.subs[:i:i], s.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// String returns the current value formatted with fmt.
+func (d *Dynamic[T]) String() string {
+	return fmt.Sprint(d.Load())
+}
+
+// set stores v and returns a function that calls the OnChange callbacks, so that they can be called once the
+// reload is complete.
+func (d *Dynamic[T]) set(v T) (notify func()) {
+	s := d.state()
+	var old T
+	if p := s.v.Swap(&v); p != nil {
+		old = *p
+	}
+
+	s.mu.Lock()
+	subs := make([]dynamicSubscriber[T], len(s.subs))
+	copy(subs, s.subs)
+	s.mu.Unlock()
+	return func() {
+		for _, sub := range subs {
+			sub.fn(old, v)
+		}
+	}
+}
+
+func (d *Dynamic[T]) dynamicType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (d *Dynamic[T]) loadValue() reflect.Value {
+	v := d.Load()
+	return reflect.ValueOf(&v).Elem()
+}
+
+func (d *Dynamic[T]) storeValue(v reflect.Value) (notify func()) {
+	p := new(T)
+	reflect.ValueOf(p).Elem().Set(v)
+	return d.set(*p)
+}
+
+// adopt makes d share the value and callbacks of from, a Dynamic of the same type.
+func (d *Dynamic[T]) adopt(from dynamicValue) {
+	d.s.Store(from.(*Dynamic[T]).state())
+}
+
+// dynamicValue is implemented by *Dynamic[T], whatever T, so that it can be handled with reflection.
+type dynamicValue interface {
+	dynamicType() reflect.Type
+	loadValue() reflect.Value
+	storeValue(v reflect.Value) (notify func())
+	adopt(from dynamicValue)
+}
+
+var dynamicValueType = reflect.TypeOf((*dynamicValue)(nil)).Elem()
+
+// asDynamic returns the Dynamic held by field, or pointed to by field, if any.
+func asDynamic(field reflect.Value) (dynamicValue, bool) {
+	if field.Kind() == reflect.Ptr && !field.IsNil() {
+		field = field.Elem()
+	}
+	if field.Kind() == reflect.Struct && field.CanAddr() && field.Addr().Type().Implements(dynamicValueType) {
+		return field.Addr().Interface().(dynamicValue), true
+	}
+	return nil, false
+}
+
+// unwrapDynamic returns the current value of a Dynamic field, or the field itself.
+func unwrapDynamic(field reflect.Value) reflect.Value {
+	if d, ok := asDynamic(field); ok {
+		return d.loadValue()
+	}
+	return field
+}
+
+// ReloadResult reports the outcome of Reload.
+type ReloadResult struct {
+	// Updated lists the environment variables of the Dynamic fields that were updated.
+	Updated []string
+	// RestartRequired lists the environment variables of the static fields whose value changed, but were left
+	// untouched.
+	RestartRequired []string
+}
+
+// Reload parses the environment variables again and updates the Dynamic fields of config in place, calling their
+// OnChange callbacks. config must have been parsed by ParseEnv. Other fields are static: they are never modified,
+// and if their value changed, Reload returns an error wrapping ErrRestartRequired that lists them, rather than
+// letting the running program silently diverge from its environment. The Dynamic fields are updated in that
+// case too. Static fields that can't be formatted, such as a *x509.CertPool, are never reported, as their values
+// can't be compared. If the environment can't be parsed, config is left untouched.
+//
+// Reload reads the variables with the lookup function of e. A configuration read from .env files or secret
+// directories should be held by a Watcher instead, which updates its Dynamic fields in the same way.
+func (e *Enviro) Reload(config any) (ReloadResult, error) {
+	var result ReloadResult
+	val := reflect.ValueOf(config)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return result, errors.New("config must be a pointer to a struct")
+	}
+
+	fresh := reflect.New(val.Elem().Type())
+	// Files opened by the fresh configuration are not used
+	defer e.release(fresh.Interface())
+	if err := e.parse(fresh.Interface(), e.prefix, e.lookupFunc()); err != nil {
+		return result, err
+	}
+
+	current, next, err := pairFields(config, fresh.Interface(), e.prefix)
+	if err != nil {
+		return result, err
+	}
+
+	type update struct {
+		d dynamicValue
+		v reflect.Value
+	}
+	var updates []update
+	for i, bf := range current {
+		nv := next[i].value
+		if d, ok := asDynamic(bf.value); ok {
+			v := unwrapDynamic(nv)
+			if !e.sameDynamicValue(d.loadValue(), v, bf.opts) {
+				updates = append(updates, update{d: d, v: v})
+				result.Updated = append(result.Updated, bf.key)
+			}
+			continue
+		}
+		// A static field that can't be formatted can't be compared reliably, e.g. two certificate pools
+		// loaded from the same file are distinct values, so it is never reported
+		if same, ok := e.sameValue(bf.value, nv, bf.opts); ok && !same {
+			result.RestartRequired = append(result.RestartRequired, bf.key)
+		}
+	}
+
+	var notify []func()
+	for _, u := range updates {
+		notify = append(notify, u.d.storeValue(u.v))
+	}
+	for _, fn := range notify {
+		fn()
+	}
+	if len(result.RestartRequired) > 0 {
+		return result, fmt.Errorf("%w: %s changed", ErrRestartRequired, strings.Join(result.RestartRequired, ", "))
+	}
+	return result, nil
+}
+
+// sameValue reports whether two values of a field are the same, comparing their formatted form, so that a file
+// opened twice or a regular expression compiled twice compare equal. It reports false for ok if either value
+// can't be formatted.
+func (e *Enviro) sameValue(a, b reflect.Value, opts Options) (same, ok bool) {
+	fa, errA := e.formatField(a, opts)
+	fb, errB := e.formatField(b, opts)
+	unsetA, unsetB := errors.Is(errA, errUnset), errors.Is(errB, errUnset)
+	if (errA != nil && !unsetA) || (errB != nil && !unsetB) {
+		return false, false
+	}
+	if unsetA || unsetB {
+		return unsetA && unsetB, true
+	}
+	return fa == fb, true
+}
+
+// sameDynamicValue is like sameValue for the values of a Dynamic field, which are compared with reflect.DeepEqual
+// if they can't be formatted: at worst, an unchanged value is stored again.
+func (e *Enviro) sameDynamicValue(a, b reflect.Value, opts Options) bool {
+	if same, ok := e.sameValue(a, b, opts); ok {
+		return same
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// ReloadOnSignal calls Reload on config when one of the given signals is received, or SIGHUP if none is given,
+// and passes its result to the optional onReload callback. The returned function stops listening for the
+// signals.
+func (e *Enviro) ReloadOnSignal(config any, onReload func(result ReloadResult, err error), sig ...os.Signal) (stop func()) {
+	return onSignal(sig, func() {
+		result, err := e.Reload(config)
+		if onReload != nil {
+			onReload(result, err)
+		}
+	})
+}
+
+// carryDynamic makes the Dynamic fields of fresh, a configuration about to replace current, share the value and
+// callbacks of those of current, and stores their new value. It returns the functions calling the OnChange
+// callbacks of the values that changed.
+func (e *Enviro) carryDynamic(current, fresh any) ([]func(), error) {
+	cur, next, err := pairFields(current, fresh, e.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var notify []func()
+	for i, bf := range cur {
+		d, ok := asDynamic(bf.value)
+		if !ok {
+			continue
+		}
+		nd, ok := asDynamic(next[i].value)
+		if !ok {
+			continue
+		}
+		v := nd.loadValue()
+		nd.adopt(d)
+		if !e.sameDynamicValue(d.loadValue(), v, bf.opts) {
+			notify = append(notify, nd.storeValue(v))
+		}
+	}
+	return notify, nil
+}
+
+// pairFields returns the fields of current and fresh, two configurations of the same type, such that the fields
+// at the same index are bound to the same variable.
+func pairFields(current, fresh any, prefix string) ([]boundField, []boundField, error) {
+	var cur, next []boundField
+	collect := func(fields *[]boundField) func(bf boundField) error {
+		return func(bf boundField) error {
+			*fields = append(*fields, bf)
+			return nil
+		}
+	}
+	if err := walkFields(current, prefix, collect(&cur)); err != nil {
+		return nil, nil, err
+	}
+	if err := walkFields(fresh, prefix, collect(&next)); err != nil {
+		return nil, nil, err
+	}
+	for i, bf := range cur {
+		// A nil nested struct in current is skipped by the walk, while the fresh config has it
+		if i >= len(next) || next[i].key != bf.key {
+			return nil, nil, fmt.Errorf("config does not match the environment: parse it with ParseEnv first")
+		}
+	}
+	return cur, next, nil
+}
@@ -0,0 +1,257 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestDynamic(t *testing.T) {
+	type Limits struct {
+		Rate  Dynamic[int]      `enviro:"rate"`
+		Burst Dynamic[[]string] `enviro:"burst"`
+	}
+
+	type Config struct {
+		Listen  string                  `enviro:"listen"`
+		Timeout Dynamic[time.Duration]  `enviro:"timeout" envopt:"unit:s" envdefault:"5"`
+		Level   Dynamic[string]         `enviro:"level" envopt:"enum:debug,info,warn"`
+		Limits  Limits                  `enviro:"nested:limits"`
+		Retries *Dynamic[time.Duration] `enviro:"retries"`
+	}
+
+	env := map[string]string{
+		"LISTEN":       ":8080",
+		"LEVEL":        "info",
+		"LIMITS_RATE":  "100",
+		"LIMITS_BURST": "a,b",
+		"RETRIES":      "3s",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	if config.Timeout.Load() != 5*time.Second || config.Level.Load() != "info" || config.Limits.Rate.Load() != 100 {
+		t.Errorf("Unexpected config %+v", &config)
+	}
+	if config.Retries == nil || config.Retries.Load() != 3*time.Second {
+		t.Errorf("Expected a pointer to a dynamic duration, got %v", config.Retries)
+	}
+
+	var changes []time.Duration
+	config.Timeout.OnChange(func(old, new time.Duration) {
+		changes = append(changes, old, new)
+	})
+
+	// Nothing changed
+	result, err := e.Reload(&config)
+	if err != nil || len(result.Updated) != 0 || len(result.RestartRequired) != 0 {
+		t.Errorf("Expected no change, got %+v (%v)", result, err)
+	}
+
+	os.Setenv("TIMEOUT", "10")
+	os.Setenv("LIMITS_BURST", "a,b,c")
+	defer os.Unsetenv("TIMEOUT")
+	result, err = e.Reload(&config)
+	if err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	if !reflect.DeepEqual(result.Updated, []string{"TIMEOUT", "LIMITS_BURST"}) {
+		t.Errorf("Expected TIMEOUT and LIMITS_BURST to be updated, got %+v", result)
+	}
+	if config.Timeout.Load() != 10*time.Second || !reflect.DeepEqual(config.Limits.Burst.Load(), []string{"a", "b", "c"}) {
+		t.Errorf("Unexpected config %+v", &config)
+	}
+	if !reflect.DeepEqual(changes, []time.Duration{5 * time.Second, 10 * time.Second}) {
+		t.Errorf("Expected a change from 5s to 10s, got %v", changes)
+	}
+
+	// A static field requires a restart, while dynamic fields are still updated
+	os.Setenv("LISTEN", ":9090")
+	os.Setenv("LEVEL", "debug")
+	result, err = e.Reload(&config)
+	if !errors.Is(err, ErrRestartRequired) || !reflect.DeepEqual(result.RestartRequired, []string{"LISTEN"}) {
+		t.Errorf("Expected LISTEN to require a restart, got %+v (%v)", result, err)
+	}
+	if config.Listen != ":8080" || config.Level.Load() != "debug" {
+		t.Errorf("Unexpected config %+v", &config)
+	}
+
+	// An invalid value leaves the config untouched
+	os.Setenv("LEVEL", "trace")
+	if _, err := e.Reload(&config); err == nil || errors.Is(err, ErrRestartRequired) {
+		t.Errorf("Expected a parsing error, got %v", err)
+	}
+	if config.Level.Load() != "debug" {
+		t.Errorf("Expected the level to be kept, got %s", config.Level.Load())
+	}
+
+	marshaled, err := e.Marshal(&config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %s", err)
+	}
+	if !contains(marshaled, "TIMEOUT=10") || !contains(marshaled, "LEVEL=debug") {
+		t.Errorf("Expected dynamic values in %q", marshaled)
+	}
+}
+
+func TestDynamicReloadFiles(t *testing.T) {
+	type Config struct {
+		Input *os.File     `enviro:"input"`
+		Level Dynamic[int] `enviro:"level"`
+	}
+
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("INPUT", input)
+	os.Setenv("LEVEL", "1")
+	defer os.Unsetenv("INPUT")
+	defer os.Unsetenv("LEVEL")
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := e.Reload(&config); err != nil {
+			t.Fatalf("Failed to reload: %s", err)
+		}
+	}
+	// The files of the fresh configurations are closed and released
+	if len(e.files) != 1 {
+		t.Errorf("Expected 1 tracked file, got %d", len(e.files))
+	}
+	if _, err := config.Input.Read(make([]byte, 1)); err != nil {
+		t.Errorf("Expected the file to be open, got %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("Failed to close files: %s", err)
+	}
+}
+
+func TestReloadUnformattable(t *testing.T) {
+	type Config struct {
+		Input *os.File           `enviro:"input"`
+		Tmpl  *template.Template `enviro:"tmpl"`
+		Pool  *x509.CertPool     `enviro:"pool" envopt:"pem:file"`
+		Level Dynamic[int]       `enviro:"level"`
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	certFile := filepath.Join(dir, "cert.pem")
+	certPEM, _ := newTestCertificate(t)
+	if err := os.WriteFile(input, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"INPUT": input, "TMPL": "Hello {{.}}", "POOL": certFile, "LEVEL": "1"}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	defer e.Close()
+
+	// Files, templates and certificate pools loaded again from an unchanged environment are not reported
+	result, err := e.Reload(&config)
+	if err != nil || len(result.RestartRequired) != 0 || len(result.Updated) != 0 {
+		t.Errorf("Expected no change, got %+v (%v)", result, err)
+	}
+
+	os.Setenv("TMPL", "Bye {{.}}")
+	result, err = e.Reload(&config)
+	if !errors.Is(err, ErrRestartRequired) || !reflect.DeepEqual(result.RestartRequired, []string{"TMPL"}) {
+		t.Errorf("Expected TMPL to require a restart, got %+v (%v)", result, err)
+	}
+}
+
+func TestWatcherDynamic(t *testing.T) {
+	type Config struct {
+		Listen string                  `enviro:"listen"`
+		Rate   Dynamic[int]            `enviro:"rate"`
+		Wait   *Dynamic[time.Duration] `enviro:"wait"`
+	}
+
+	env := map[string]string{"LISTEN": ":8080", "RATE": "10", "WAIT": "1s"}
+	e := New()
+	e.SetLookupFunc(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	w := NewWatcher[Config](e)
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	first := w.Load()
+
+	var rates []int
+	first.Rate.OnChange(func(old, new int) {
+		rates = append(rates, old, new)
+	})
+	var waits []time.Duration
+	first.Wait.OnChange(func(old, new time.Duration) {
+		waits = append(waits, old, new)
+	})
+
+	// Callbacks registered on the first configuration keep being called across reloads
+	env["RATE"] = "20"
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	env["RATE"] = "30"
+	env["LISTEN"] = ":9090"
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	if !reflect.DeepEqual(rates, []int{10, 20, 20, 30}) {
+		t.Errorf("Expected rates 10 -> 20 -> 30, got %v", rates)
+	}
+	if len(waits) != 0 {
+		t.Errorf("Expected no wait change, got %v", waits)
+	}
+	if first.Rate.Load() != 30 || w.Load().Rate.Load() != 30 || w.Load().Listen != ":9090" {
+		t.Errorf("Expected the rate of every configuration to be 30, got %d and %d", first.Rate.Load(), w.Load().Rate.Load())
+	}
+
+	env["WAIT"] = "2s"
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	if !reflect.DeepEqual(waits, []time.Duration{time.Second, 2 * time.Second}) {
+		t.Errorf("Expected wait 1s -> 2s, got %v", waits)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Integer is a constraint that permits any integer type.
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Enum is a constraint that permits the types that can be used as enumerations.
+type Enum interface {
+	Integer | ~string
+}
+
+// enumTable maps case-insensitive names to the values of an enumeration or a set of bit flags.
+type enumTable struct {
+	values map[string]reflect.Value
+	names  []string
+	flags  bool
+}
+
+var enumTables = make(map[reflect.Type]*enumTable)
+
+// enumDefs caches the tables parsed from the `enum` and `flags` options, so that the definition of a field is
+// parsed once rather than for every element of a slice or a map.
+var enumDefs sync.Map
+
+type enumDef struct {
+	typ   reflect.Type
+	def   string
+	flags bool
+}
+
+type parsedEnum struct {
+	table *enumTable
+	err   error
+}
+
+// RegisterEnum registers the names of the values of type T, so that fields of type T are parsed from a
+// case-insensitive name instead of their underlying representation. Parsing fails for unknown names, with an
+// error listing the valid ones. For example:
+//
+//	type Mode int
+//
+//	const (
+//		Dev Mode = iota
+//		Staging
+//		Prod
+//	)
+//
+//	func init() {
+//		enviro.RegisterEnum(map[string]Mode{"dev": Dev, "staging": Staging, "prod": Prod})
+//	}
+//
+// An enumeration can also be declared for a single field with the `enum` option, e.g.
+// `envopt:"enum:dev=0,staging=1,prod=2"`. Each value has a single name, so that it is always formatted the same
+// way: RegisterEnum panics if two names, compared without case, are the same or share a value, as does ParseEnv
+// for the `enum` option. It is safe to call RegisterEnum concurrently.
+func RegisterEnum[T Enum](names map[string]T) {
+	registerEnum(names, false)
+}
+
+// RegisterFlags is like RegisterEnum for a set of bit flags: the names of several flags can be combined with a
+// "|", as in "read|write", and the resulting value is the bitwise OR of the flags. An empty value yields the
+// zero value. A set of flags can also be declared for a single field with the `flags` option, e.g.
+// `envopt:"flags:read=1,write=2,exec=4"`.
+func RegisterFlags[T Integer](names map[string]T) {
+	registerEnum(names, true)
+}
+
+func registerEnum[T Enum](names map[string]T, flags bool) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	// Sorting makes the error, if any, the same from a run to the next
+	sort.Strings(sorted)
+
+	table := &enumTable{values: make(map[string]reflect.Value, len(names)), flags: flags}
+	for _, name := range sorted {
+		if err := table.add(strings.ToLower(name), reflect.ValueOf(names[name])); err != nil {
+			panic(fmt.Sprintf("enviro: invalid enum %s: %s", typ, err))
+		}
+	}
+	sort.Strings(table.names)
+
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	enumTables[typ] = table
+}
+
+// add adds a name and its value to the table. Names and values are unique, so that a value is formatted with the
+// same name from a run to the next.
+func (t *enumTable) add(name string, v reflect.Value) error {
+	if _, ok := t.values[name]; ok {
+		return fmt.Errorf("duplicate name %q", name)
+	}
+	for _, other := range t.names {
+		if t.values[other].Interface() == v.Interface() {
+			return fmt.Errorf("names %q and %q have the same value %v", other, name, v.Interface())
+		}
+	}
+	t.values[name] = v
+	t.names = append(t.names, name)
+	return nil
+}
+
+// setEnumField parses the value with the enumeration declared by the `enum` or `flags` option of the field, or
+// registered for its type. It reports whether the field is an enumeration.
+func (e *Enviro) setEnumField(field reflect.Value, value string, opts Options) (bool, error) {
+	// Leave slices, arrays and maps to their own setter, so that the option applies to their elements
+	switch field.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return false, nil
+	}
+
+	table, err := lookupEnumTable(field.Type(), opts)
+	if err != nil {
+		return true, err
+	}
+	if table == nil {
+		return false, nil
+	}
+	return true, table.set(field, value)
+}
+
+// lookupEnumTable returns the enum table defined by the `enum` or `flags` option, or registered for typ. It
+// returns a nil table if typ is not an enumeration.
+func lookupEnumTable(typ reflect.Type, opts Options) (*enumTable, error) {
+	if def, ok := opts.Lookup("enum"); ok {
+		return cachedEnumTable(enumDef{typ: typ, def: def})
+	}
+	if def, ok := opts.Lookup("flags"); ok {
+		return cachedEnumTable(enumDef{typ: typ, def: def, flags: true})
+	}
+
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	return enumTables[typ], nil
+}
+
+func cachedEnumTable(key enumDef) (*enumTable, error) {
+	if v, ok := enumDefs.Load(key); ok {
+		p := v.(parsedEnum)
+		return p.table, p.err
+	}
+	table, err := parseEnumTable(key.typ, key.def, key.flags)
+	enumDefs.Store(key, parsedEnum{table: table, err: err})
+	return table, err
+}
+
+func (t *enumTable) set(field reflect.Value, value string) error {
+	if !t.flags {
+		v, ok := t.values[strings.ToLower(strings.TrimSpace(value))]
+		if !ok {
+			return fmt.Errorf("invalid value %q: expected one of %s", value, strings.Join(t.names, ", "))
+		}
+		field.Set(v.Convert(field.Type()))
+		return nil
+	}
+
+	var bits uint64
+	if strings.TrimSpace(value) != "" {
+		for _, name := range strings.Split(value, "|") {
+			v, ok := t.values[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return fmt.Errorf("invalid flag %q: expected a combination of %s", strings.TrimSpace(name), strings.Join(t.names, ", "))
+			}
+			if v.CanInt() {
+				bits |= uint64(v.Int())
+			} else {
+				bits |= v.Uint()
+			}
+		}
+	}
+
+	if field.CanInt() {
+		field.SetInt(int64(bits))
+	} else {
+		field.SetUint(bits)
+	}
+	return nil
+}
+
+// format returns the name of the value held by field, or the names of its bits separated by "|" for flags.
+func (t *enumTable) format(field reflect.Value) (string, error) {
+	if !t.flags {
+		for _, name := range t.names {
+			if v := t.values[name]; v.Convert(field.Type()).Interface() == field.Interface() {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("value %v has no name in %s", field.Interface(), strings.Join(t.names, ", "))
+	}
+
+	var bits uint64
+	if field.CanInt() {
+		bits = uint64(field.Int())
+	} else {
+		bits = field.Uint()
+	}
+
+	var names []string
+	for _, name := range t.names {
+		v := t.values[name]
+		var flag uint64
+		if v.CanInt() {
+			flag = uint64(v.Int())
+		} else {
+			flag = v.Uint()
+		}
+		if flag != 0 && bits&flag == flag {
+			names = append(names, name)
+			bits &^= flag
+		}
+	}
+	if bits != 0 {
+		return "", fmt.Errorf("flags %#x have no name in %s", bits, strings.Join(t.names, ", "))
+	}
+	return strings.Join(names, "|"), nil
+}
+
+// parseEnumTable parses the definition of an enumeration from the `enum` or `flags` option. Each entry is
+// either a name=value pair, or a bare name whose value is the name itself for string types, and its position
+// in the list for integer types (like constants declared with iota), or the corresponding bit for flags.
+func parseEnumTable(typ reflect.Type, def string, flags bool) (*enumTable, error) {
+	if flags && typ.Kind() == reflect.String {
+		return nil, fmt.Errorf("flags option is not supported for %s", typ.String())
+	}
+
+	table := &enumTable{values: make(map[string]reflect.Value), flags: flags}
+	for i, entry := range strings.Split(def, ",") {
+		name, raw, found := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		raw = strings.TrimSpace(raw)
+		if name == "" {
+			return nil, fmt.Errorf("invalid enum definition %q: empty name", def)
+		}
+
+		v := reflect.New(typ).Elem()
+		switch {
+		case typ.Kind() == reflect.String:
+			if !found {
+				raw = name
+			}
+			v.SetString(raw)
+		case v.CanInt():
+			if !found {
+				raw = implicitEnumValue(i, flags)
+			}
+			n, err := strconv.ParseInt(raw, 0, typ.Bits())
+			if err != nil {
+				return nil, fmt.Errorf("invalid enum definition %q: invalid value %q for %s", def, raw, name)
+			}
+			v.SetInt(n)
+		case v.CanUint():
+			if !found {
+				raw = implicitEnumValue(i, flags)
+			}
+			n, err := strconv.ParseUint(raw, 0, typ.Bits())
+			if err != nil {
+				return nil, fmt.Errorf("invalid enum definition %q: invalid value %q for %s", def, raw, name)
+			}
+			v.SetUint(n)
+		default:
+			return nil, fmt.Errorf("enum option is not supported for %s", typ.String())
+		}
+
+		if err := table.add(name, v); err != nil {
+			return nil, fmt.Errorf("invalid enum definition %q: %w", def, err)
+		}
+	}
+	return table, nil
+}
+
+func implicitEnumValue(i int, flags bool) string {
+	if flags {
+		return strconv.FormatUint(1<<i, 10)
+	}
+	return strconv.Itoa(i)
+}
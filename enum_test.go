@@ -0,0 +1,166 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testMode int
+
+const (
+	modeDev testMode = iota
+	modeStaging
+	modeProd
+)
+
+type testPerm uint8
+
+const (
+	permRead testPerm = 1 << iota
+	permWrite
+	permExec
+)
+
+type testStrategy string
+
+func TestParseEnvEnum(t *testing.T) {
+	type Config struct {
+		Mode      testMode             `enviro:"mode"`
+		Modes     []testMode           `enviro:"modes"`
+		Perm      testPerm             `enviro:"perm"`
+		Levels    map[string]*testMode `enviro:"levels"`
+		Strategy  testStrategy         `enviro:"strategy" envopt:"enum:round_robin,least_conn,random"`
+		Priority  int                  `enviro:"priority" envopt:"enum:low=-1,normal=0,high=1"`
+		Mask      uint16               `enviro:"mask" envopt:"flags:a,b,c"`
+		Fallbacks []testStrategy       `enviro:"fallbacks" envopt:"enum:random=rnd,first"`
+	}
+
+	restoreRegistry(t)
+	RegisterEnum(map[string]testMode{"dev": modeDev, "staging": modeStaging, "prod": modeProd})
+	RegisterFlags(map[string]testPerm{"read": permRead, "write": permWrite, "exec": permExec})
+
+	env := map[string]string{
+		"MODE":      "Prod",
+		"MODES":     "dev, STAGING",
+		"PERM":      "read|write",
+		"LEVELS":    "api=prod",
+		"STRATEGY":  "Least_Conn",
+		"PRIORITY":  "high",
+		"MASK":      "a | c",
+		"FALLBACKS": "random,first",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse enum environment variables: %s", err)
+	}
+
+	prod := modeProd
+	expected := Config{
+		Mode:      modeProd,
+		Modes:     []testMode{modeDev, modeStaging},
+		Perm:      permRead | permWrite,
+		Levels:    map[string]*testMode{"api": &prod},
+		Strategy:  "least_conn",
+		Priority:  1,
+		Mask:      5,
+		Fallbacks: []testStrategy{"rnd", "first"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	os.Setenv("MODE", "qa")
+	err := e.ParseEnv(&config)
+	if err == nil || !strings.Contains(err.Error(), `invalid value "qa": expected one of dev, prod, staging`) {
+		t.Errorf("Expected an unknown name error, got %v", err)
+	}
+
+	os.Setenv("MODE", "dev")
+	os.Setenv("PERM", "read|delete")
+	err = e.ParseEnv(&config)
+	if err == nil || !strings.Contains(err.Error(), `invalid flag "delete": expected a combination of exec, read, write`) {
+		t.Errorf("Expected an unknown flag error, got %v", err)
+	}
+}
+
+func TestParseEnvEnumMapKeys(t *testing.T) {
+	type Config struct {
+		Modes map[string]testMode  `enviro:"modes" envopt:"enum:dev=0,prod=2"`
+		Perms map[string]testPerm  `enviro:"perms" envopt:"flags:r,w,x"`
+		Names map[testStrategy]int `enviro:"names" envopt:"enum:low=1,high=2"`
+	}
+
+	os.Setenv("MODES", "a=dev, b=prod")
+	os.Setenv("PERMS", "x=r|w")
+	os.Setenv("NAMES", "random=high")
+	defer os.Unsetenv("MODES")
+	defer os.Unsetenv("PERMS")
+	defer os.Unsetenv("NAMES")
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	expected := Config{
+		Modes: map[string]testMode{"a": modeDev, "b": modeProd},
+		Perms: map[string]testPerm{"x": 3},
+		Names: map[testStrategy]int{"random": 2},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	env, err := e.Marshal(&config)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %s", err)
+	}
+	want := []string{"MODES=a=dev,b=prod", "PERMS=x=r|w", "NAMES=random=high"}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("Expected %+v, got %+v", want, env)
+	}
+}
+
+func TestEnumDuplicates(t *testing.T) {
+	restoreRegistry(t)
+
+	for _, names := range []map[string]testMode{
+		{"dev": modeDev, "development": modeDev},
+		{"dev": modeDev, "DEV": modeStaging},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected RegisterEnum to panic for %v", names)
+				}
+			}()
+			RegisterEnum(names)
+		}()
+	}
+
+	type Config struct {
+		Priority int `enviro:"priority" envopt:"enum:low,normal,high=1"`
+	}
+	os.Setenv("PRIORITY", "low")
+	defer os.Unsetenv("PRIORITY")
+	err := New().ParseEnv(&Config{})
+	if err == nil || !strings.Contains(err.Error(), `names "normal" and "high" have the same value 1`) {
+		t.Errorf("Expected a duplicate value error, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+// Package envhttp exposes the effective configuration described by an enviro.Describer over HTTP and expvar.
+// Secret values are always redacted, but the handler still reveals the shape of the configuration, so it is
+// meant to be mounted on an internal admin port.
+package envhttp
+
+import (
+	"encoding/json"
+	"expvar"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/tigerwill90/enviro"
+)
+
+var page = template.Must(template.New("config").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Configuration</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+td.value { font-family: monospace; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<table>
+<tr><th>Key</th><th>Field</th><th>Type</th><th>Value</th><th>Source</th><th>Default</th><th>Required</th><th>Description</th></tr>
+{{- range .}}
+<tr><td>{{.Key}}</td><td>{{.Field}}</td><td>{{.Type}}</td><td class="value">{{if .Set}}{{.Value}}{{else}}<em>nil</em>{{end}}</td><td>{{.Source}}</td><td>{{.Default}}</td><td>{{if .Required}}yes{{end}}</td><td>{{.Description}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+type document struct {
+	Fields []enviro.FieldInfo `json:"fields"`
+}
+
+// Handler returns an http.Handler that renders the configuration described by d. It responds with JSON if the
+// request accepts "application/json" or has the query parameter "format=json", and with an HTML table otherwise.
+func Handler(d enviro.Describer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		fields, err := d.Describe()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if fields == nil {
+			fields = []enviro.FieldInfo{}
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(document{Fields: fields})
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = page.Execute(w, fields)
+	})
+}
+
+// Publish publishes the configuration described by d as an expvar variable with the given name, which is
+// then served by the expvar handler at /debug/vars. Like expvar.Publish, it panics if the name is already
+// registered. The variable holds the fields as they would appear in the JSON response of Handler, or an
+// "error" member if d fails to describe the configuration.
+func Publish(name string, d enviro.Describer) {
+	expvar.Publish(name, expvar.Func(func() any {
+		fields, err := d.Describe()
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+		if fields == nil {
+			fields = []enviro.FieldInfo{}
+		}
+		return document{Fields: fields}
+	}))
+}
+
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if strings.TrimSpace(mediaType) == "application/json" {
+				return true
+			}
+		}
+	}
+	return false
+}
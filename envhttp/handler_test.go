@@ -0,0 +1,102 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package envhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tigerwill90/enviro"
+)
+
+var fields = []enviro.FieldInfo{
+	{Key: "LEVEL", Field: "Level", Type: "string", Value: "info", Set: true, Source: enviro.SourceDefault, Default: "info"},
+	{Key: "PASSWORD", Field: "Password", Type: "string", Value: "[REDACTED]", Set: true, Source: enviro.SourceEnv, Secret: true},
+	{Key: "NOTE", Field: "Note", Type: "string", Value: "<b>bold</b>", Set: true, Source: enviro.SourceEnv},
+}
+
+var describer = enviro.DescriberFunc(func() ([]enviro.FieldInfo, error) {
+	return fields, nil
+})
+
+func TestHandlerJSON(t *testing.T) {
+	for _, target := range []string{"/?format=json", "/"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if target == "/" {
+			req.Header.Set("Accept", "text/plain, application/json;q=0.9")
+		}
+		rec := httptest.NewRecorder()
+		Handler(describer).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected application/json, got %q", ct)
+		}
+		var doc struct {
+			Fields []enviro.FieldInfo `json:"fields"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(doc.Fields, fields) {
+			t.Errorf("Expected %+v, got %+v", fields, doc.Fields)
+		}
+	}
+}
+
+func TestHandlerHTML(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler(describer).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, s := range []string{"<td>LEVEL</td>", "<td>default</td>", "[REDACTED]", "&lt;b&gt;bold&lt;/b&gt;"} {
+		if !strings.Contains(body, s) {
+			t.Errorf("Expected body to contain %q, got %s", s, body)
+		}
+	}
+}
+
+func TestHandlerError(t *testing.T) {
+	failing := enviro.DescriberFunc(func() ([]enviro.FieldInfo, error) {
+		return nil, errors.New("configuration not loaded")
+	})
+
+	rec := httptest.NewRecorder()
+	Handler(failing).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	Handler(describer).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	Publish("envhttp_test_config", describer)
+
+	var doc struct {
+		Fields []enviro.FieldInfo `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("envhttp_test_config").String()), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.Fields, fields) {
+		t.Errorf("Expected %+v, got %+v", fields, doc.Fields)
+	}
+}
@@ -5,16 +5,20 @@
 package enviro
 
 import (
+	"encoding"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"gopkg.in/yaml.v3"
-	"net"
-	"net/url"
+	"io"
+	"io/fs"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 )
 
@@ -27,12 +31,55 @@ type ParseField interface {
 	ParseField(value string) error
 }
 
-var parserType = reflect.TypeOf((*ParseField)(nil)).Elem()
+// ParseFieldWithOptions is like ParseField but gives access to the field being parsed and its options. Types
+// configured through the `envopt` tag, like time.Time and *os.File, should implement this interface. It takes
+// precedence over ParseField when a type implements both.
+type ParseFieldWithOptions interface {
+	// ParseFieldWithOptions parses the provided string value and sets the receiver accordingly.
+	// It returns an error if the value cannot be parsed into the expected type.
+	ParseFieldWithOptions(value string, opts FieldOptions) error
+}
+
+// FieldOptions describes the field being parsed by a ParseFieldWithOptions implementation.
+type FieldOptions struct {
+	// Enviro is the instance parsing the field.
+	Enviro *Enviro
+	// Key is the name of the environment variable, including any prefix.
+	Key string
+	// Options is the parsed `envopt` tag of the field. Its Raw method returns the tag as written.
+	Options Options
+	// Field is the struct field being parsed. Other struct tags can be read from Field.Tag.
+	Field reflect.StructField
+}
+
+var (
+	parserWithOptionsType = reflect.TypeOf((*ParseFieldWithOptions)(nil)).Elem()
+	parserType            = reflect.TypeOf((*ParseField)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	flagValueType         = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	jsonUnmarshalerType   = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
 
 // Enviro facilitates the loading and parsing of environment variables into Go structs.
 // It supports custom prefixes for environment variables, nested struct parsing, and fields of various types.
 type Enviro struct {
-	prefix string
+	parsers         map[reflect.Type]valueParser
+	formatters      map[reflect.Type]valueFormatter
+	loc             *time.Location
+	now             func() time.Time
+	prefix          string
+	timeLayouts     []string
+	boolMode        BoolMode
+	templateFuncs   template.FuncMap
+	base            int
+	hasBase         bool
+	jsonUnmarshaler bool
+	lookup          func(key string) (string, bool)
+	baseDir         string
+	fsys            fs.FS
+	mu              sync.Mutex
+	files           map[io.Closer]struct{}
+	logDefaults     bool
 }
 
 // New creates and returns a new instance of the Enviro parser.
@@ -46,6 +93,79 @@ func (e *Enviro) SetEnvPrefix(prefix string) {
 	e.prefix = prefix
 }
 
+// SetTimeLocation sets the location used to interpret time.Time values that don't specify a time zone, as well
+// as "today" in relative times. A location set in the `envopt:"time:layout,location"` option of a field takes
+// precedence. The default location is time.UTC.
+func (e *Enviro) SetTimeLocation(loc *time.Location) {
+	e.loc = loc
+}
+
+// SetTimeLayouts sets the ordered list of layouts tried when parsing a time.Time field that doesn't specify its
+// own layouts with the `time` option. Besides time.Parse layouts, the list may contain the "unix", "unixmilli",
+// "unixmicro", "unixnano" and "relative" formats. Calling SetTimeLayouts without argument restores the default
+// list of common layouts.
+func (e *Enviro) SetTimeLayouts(layouts ...string) {
+	e.timeLayouts = layouts
+}
+
+// SetIntBase sets the base used to parse integer fields that don't specify one with the `base` option, e.g.
+// `envopt:"base:16"`. Base 0 follows the Go syntax for integer literals: the base is implied by the prefix
+// ("0b", "0o" or "0", "0x") and underscores may separate digits, as in "0o755", "0x1F" or "1_000_000". The
+// default base is 10. os.FileMode fields are parsed in octal unless the value has a prefix, or the field sets
+// its own base.
+func (e *Enviro) SetIntBase(base int) {
+	e.base = base
+	e.hasBase = true
+}
+
+// SetTemplateFuncs sets the functions available to *template.Template fields, which are parsed when the
+// configuration is loaded so that invalid templates fail at startup.
+func (e *Enviro) SetTemplateFuncs(funcs template.FuncMap) {
+	e.templateFuncs = funcs
+}
+
+// SetLookupFunc sets the function used to look up environment variables, instead of os.LookupEnv. It allows
+// parsing a configuration from another source, such as a map in tests or a .env file. Setting nil restores
+// os.LookupEnv.
+func (e *Enviro) SetLookupFunc(fn func(key string) (string, bool)) {
+	e.lookup = fn
+}
+
+func (e *Enviro) lookupFunc() func(key string) (string, bool) {
+	if e.lookup != nil {
+		return e.lookup
+	}
+	return os.LookupEnv
+}
+
+// SetBaseDir sets the directory relative paths are resolved against, for Path fields and string fields with the
+// `path` option. By default, relative paths are kept relative to the working directory.
+func (e *Enviro) SetBaseDir(dir string) {
+	e.baseDir = dir
+}
+
+// SetFS sets the filesystem used to check Path fields, instead of the operating system filesystem. Paths are
+// converted to the unrooted, slash separated form of fs.FS, so a fstest.MapFS can stand for the root directory in
+// tests. As fs.FS is read-only, the writable check only looks at permission bits, and `mkdir` requires the
+// filesystem to implement a MkdirAll(name string, perm fs.FileMode) error method.
+func (e *Enviro) SetFS(fsys fs.FS) {
+	e.fsys = fsys
+}
+
+// UseJSONUnmarshaler enables or disables the use of the json.Unmarshaler interface as a parsing hook. When
+// enabled, types implementing json.Unmarshaler are parsed with their UnmarshalJSON method if they implement
+// neither ParseField, encoding.TextUnmarshaler nor flag.Value. A value that is not valid JSON is passed
+// as a JSON string. The hook is always used for fields with the `envopt:"json"` option.
+func (e *Enviro) UseJSONUnmarshaler(enable bool) {
+	e.jsonUnmarshaler = enable
+}
+
+// SetLogDefaults enables or disables the "defaults" attribute of LogConfig, which lists the variables whose value
+// comes from their envdefault tag.
+func (e *Enviro) SetLogDefaults(enable bool) {
+	e.logDefaults = enable
+}
+
 // ParseEnvWithPrefix parses environment variables into the provided struct based on struct tags.
 // It uses the specified prefix to look up environment variables, allowing for nested struct parsing
 // and the application of custom parsing logic for specific fields. The function returns an error
@@ -55,6 +175,11 @@ func (e *Enviro) SetEnvPrefix(prefix string) {
 // variable values. If the struct contains nested structs and the tag `enviro:"nested:your_prefix"`, the prefix is
 // concatenated with "_" and the nested struct's tag to form the complete environment variable name.
 func (e *Enviro) ParseEnvWithPrefix(config any, prefix string) error {
+	return e.parse(config, prefix, e.lookupFunc())
+}
+
+// parse is ParseEnvWithPrefix with the environment variables looked up with the given function.
+func (e *Enviro) parse(config any, prefix string, lookup func(key string) (string, bool)) error {
 	val := reflect.ValueOf(config)
 	if val.Kind() != reflect.Ptr || val.IsNil() {
 		return errors.New("config must be a pointer to a struct")
@@ -89,9 +214,9 @@ func (e *Enviro) ParseEnvWithPrefix(config any, prefix string) error {
 					// Recursively load the nested struct or the newly instantiated struct
 					var err error
 					if nestedStruct.Kind() == reflect.Ptr {
-						err = e.ParseEnvWithPrefix(nestedStruct.Interface(), envPrefix)
+						err = e.parse(nestedStruct.Interface(), envPrefix, lookup)
 					} else {
-						err = e.ParseEnvWithPrefix(nestedStruct.Addr().Interface(), envPrefix)
+						err = e.parse(nestedStruct.Addr().Interface(), envPrefix, lookup)
 					}
 
 					if err != nil {
@@ -105,12 +230,12 @@ func (e *Enviro) ParseEnvWithPrefix(config any, prefix string) error {
 			continue
 		}
 
-		envKey, omitprefix, required := parseTag(tag)
+		envKey, omitprefix, required, _ := parseTag(tag)
 		if !omitprefix && prefix != "" {
 			envKey = prefix + "_" + envKey
 		}
 
-		envValue, exists := os.LookupEnv(strings.ToUpper(envKey))
+		envValue, exists := lookup(strings.ToUpper(envKey))
 		if required && !exists {
 			return fmt.Errorf("missing required environment variable: %s", strings.ToUpper(envKey))
 		}
@@ -118,13 +243,21 @@ func (e *Enviro) ParseEnvWithPrefix(config any, prefix string) error {
 			return fmt.Errorf("empty required environment variable: %s", strings.ToUpper(envKey))
 		}
 
-		if envValue == "" {
+		opts, err := parseOptions(envOpt)
+		if err != nil {
+			return fmt.Errorf("invalid envopt tag for field %s: %w", fieldType.Name, err)
+		}
+		opts.key = strings.ToUpper(envKey)
+		opts.field = fieldType
+
+		// A presence flag set to an empty value is true, the default only applies when it is unset
+		if envValue == "" && !(exists && isPresenceFlag(opts)) {
 			envValue = envDef
 		}
 
 		if exists || envValue != "" {
-			if err := e.setField(field, envValue, envOpt); err != nil {
-				return fmt.Errorf("failed to parse environment variable %s: %w", strings.ToUpper(envKey), err)
+			if err := e.setField(field, envValue, opts); err != nil {
+				return variableError("parse", strings.ToUpper(envKey), err)
 			}
 		}
 	}
@@ -144,49 +277,57 @@ func (e *Enviro) MustParseEnv(config any) {
 	}
 }
 
-func parseTag(tag string) (key string, omitprefix, required bool) {
+// parseTag parses the `enviro` tag: the variable name followed by the required, omitprefix and secret flags, in
+// any order.
+func parseTag(tag string) (key string, omitprefix, required, secret bool) {
 	parts := strings.Split(tag, ",")
 	key = strings.TrimSpace(parts[0])
-	if len(parts) > 2 {
-		if strings.TrimSpace(parts[1]) == "required" {
+	for _, part := range parts[1:] {
+		switch strings.TrimSpace(part) {
+		case "required":
 			required = true
-		}
-		if strings.TrimSpace(parts[2]) == "omitprefix" {
+		case "omitprefix":
 			omitprefix = true
+		case "secret":
+			secret = true
 		}
-		return
 	}
+	return
+}
 
-	if len(parts) > 1 {
-		if strings.TrimSpace(parts[1]) == "required" {
-			required = true
+func parseTimeFormatTag(opts Options) (layouts []string, location string) {
+	if value, ok := opts.Lookup("time"); ok {
+		// Layouts such as time.RFC1123 contain a comma, so what follows the last comma is only the location if
+		// it is the name of a known location.
+		if i := strings.LastIndex(value, ","); i >= 0 {
+			if name := strings.TrimSpace(value[i+1:]); isLocation(name) {
+				location = name
+				value = value[:i]
+			}
 		}
-		if strings.TrimSpace(parts[1]) == "omitprefix" {
-			omitprefix = true
+		for _, layout := range strings.Split(value, "|") {
+			if layout = strings.TrimSpace(layout); layout != "" {
+				layouts = append(layouts, layout)
+			}
 		}
 	}
-
 	return
 }
 
-func parseTimeFormatTag(tag string) (format, location string) {
-	if strings.HasPrefix(tag, "time:") {
-		parts := strings.Split(strings.TrimPrefix(tag, "time:"), ",")
-		format = strings.TrimSpace(parts[0])
-		if len(parts) > 1 {
-			location = strings.TrimSpace(parts[1])
-		}
+func isLocation(name string) bool {
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return false
 	}
-	return
+	_, err := time.LoadLocation(name)
+	return err == nil
 }
 
-func parseFileFormatTag(tag string) (flag int, perm os.FileMode) {
+func parseFileFormatTag(opts Options) (flag int, perm os.FileMode) {
 	// Default to read-only if no specific options are provided
 	flag = os.O_RDONLY // Default flag
 	perm = 0666        // Default permission for new files
 
-	if strings.HasPrefix(tag, "file:") {
-		options := strings.TrimPrefix(tag, "file:")
+	if options, ok := opts.Lookup("file"); ok {
 		parts := strings.Split(options, ",")
 
 		// Assume the first part specifies flags and the second part specifies permissions
@@ -194,13 +335,15 @@ func parseFileFormatTag(tag string) (flag int, perm os.FileMode) {
 			part = strings.TrimSpace(part)
 			opts := strings.Split(part, "|")
 			for _, opt := range opts {
+				// The access mode only replaces the access bits, so it can be given in any position
+				const access = os.O_RDONLY | os.O_WRONLY | os.O_RDWR
 				switch strings.TrimSpace(opt) {
 				case "ro":
-					flag = os.O_RDONLY
+					flag = flag&^access | os.O_RDONLY
 				case "wo":
-					flag = os.O_WRONLY
+					flag = flag&^access | os.O_WRONLY
 				case "rw":
-					flag = os.O_RDWR
+					flag = flag&^access | os.O_RDWR
 				case "create":
 					flag |= os.O_CREATE
 				case "truncate":
@@ -219,7 +362,20 @@ func parseFileFormatTag(tag string) (flag int, perm os.FileMode) {
 	return flag, perm
 }
 
-func (e *Enviro) setField(field reflect.Value, value, opt string) error {
+func (e *Enviro) setField(field reflect.Value, value string, opts Options) error {
+	// A parser registered for the exact field type (e.g. *big.Int) wins over everything else
+	if parser, ok := e.lookupParser(field.Type()); ok {
+		return parser(field, value)
+	}
+
+	// Some built-in types are only supported through a pointer, an interface or a slice (e.g. *x509.CertPool,
+	// crypto.PrivateKey or []*x509.Certificate), and are matched on the exact field type.
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice:
+		if setter, ok := builtinSetters[field.Type()]; ok {
+			return setter(e, field, value, opts)
+		}
+	}
 
 	// Determine if the field is a pointer and get the element type
 	isPtr := field.Type().Kind() == reflect.Ptr
@@ -243,7 +399,37 @@ func (e *Enviro) setField(field reflect.Value, value, opt string) error {
 	}
 
 	var err error
-	// Check if the type implements the ParseField interface
+	var handled bool
+	if parser, ok := e.lookupParser(elemType); ok {
+		err = parser(target, value)
+		goto SET_FIELD
+	}
+
+	// A Dynamic field is parsed as its underlying type
+	if d, ok := asDynamic(target); ok {
+		v := reflect.New(d.dynamicType()).Elem()
+		if err = e.setField(v, value, opts); err == nil {
+			d.storeValue(v)()
+		}
+		goto SET_FIELD
+	}
+
+	if handled, err = e.setEnumField(target, value, opts); handled {
+		goto SET_FIELD
+	}
+
+	// Check if the type implements the ParseFieldWithOptions or ParseField interface
+	if target.Addr().Type().Implements(parserWithOptionsType) {
+		parser := target.Addr().Interface().(ParseFieldWithOptions)
+		err = parser.ParseFieldWithOptions(value, FieldOptions{
+			Enviro:  e,
+			Key:     opts.key,
+			Options: opts,
+			Field:   opts.field,
+		})
+		goto SET_FIELD
+	}
+
 	if target.Addr().Type().Implements(parserType) {
 		// The field implements ParseField interface, delegate parsing to it
 		parser := target.Addr().Interface().(ParseField)
@@ -251,23 +437,39 @@ func (e *Enviro) setField(field reflect.Value, value, opt string) error {
 		goto SET_FIELD
 	}
 
+	// Built-in types take precedence over the standard parsing interfaces they may implement
+	if setter, ok := builtinSetters[elemType]; ok {
+		err = setter(e, target, value, opts)
+		goto SET_FIELD
+	}
+
+	if handled, err = e.setUnmarshalerField(target, value, opts); handled {
+		goto SET_FIELD
+	}
+
 	switch elemType.Kind() {
 	case reflect.String:
-		err = e.setStringField(target, value)
+		if opts.Has("path") {
+			err = setPath(e, target, value, opts)
+		} else {
+			err = e.setStringField(target, value)
+		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		err = e.setIntField(target, value)
+		err = e.setIntField(target, value, opts)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		err = e.setUintField(target, value)
+		err = e.setUintField(target, value, opts)
 	case reflect.Float32, reflect.Float64:
 		err = e.setFloatField(target, value)
 	case reflect.Bool:
-		err = e.setBoolField(target, value)
+		err = e.setBoolField(target, value, opts)
 	case reflect.Struct:
-		err = e.setStructField(target, value, opt)
+		err = e.setStructField(target, value, opts)
 	case reflect.Slice:
-		err = e.setSliceField(target, value, opt)
+		err = e.setSliceField(target, value, opts)
+	case reflect.Array:
+		err = e.setArrayField(target, value, opts)
 	case reflect.Map:
-		err = e.setMapField(target, value, opt)
+		err = e.setMapField(target, value, opts)
 	default:
 		err = errors.New("unsupported field type")
 	}
@@ -288,21 +490,44 @@ SET_FIELD:
 	return nil
 }
 
+// setUnmarshalerField parses the value with one of the standard parsing interfaces implemented by the
+// field, in order of precedence: encoding.TextUnmarshaler, flag.Value and, if enabled, json.Unmarshaler.
+// It reports whether the field implements any of them.
+func (e *Enviro) setUnmarshalerField(field reflect.Value, value string, opts Options) (bool, error) {
+	ptr := field.Addr()
+	switch {
+	case ptr.Type().Implements(textUnmarshalerType):
+		return true, ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	case ptr.Type().Implements(flagValueType):
+		return true, ptr.Interface().(flag.Value).Set(value)
+	case ptr.Type().Implements(jsonUnmarshalerType) && (e.jsonUnmarshaler || opts.Has("json")):
+		value, err := decodeBlob(value, opts)
+		if err != nil {
+			return true, err
+		}
+		data := []byte(value)
+		if !json.Valid(data) {
+			data, _ = json.Marshal(value)
+		}
+		return true, ptr.Interface().(json.Unmarshaler).UnmarshalJSON(data)
+	}
+	return false, nil
+}
+
 func (e *Enviro) setStringField(field reflect.Value, value string) error {
-	field.Set(reflect.ValueOf(value))
+	field.SetString(value)
 	return nil
 }
 
-func (e *Enviro) setIntField(field reflect.Value, value string) error {
-	if field.Type() == reflect.TypeOf(time.Duration(0)) {
-		d, err := time.ParseDuration(value)
-		if err != nil {
-			return err
-		}
-		field.SetInt(int64(d))
-		return nil
+func (e *Enviro) setIntField(field reflect.Value, value string, opts Options) error {
+	if opts.Has("bytes") {
+		return e.setByteSizeField(field, value)
+	}
+	base, err := e.intBase(opts)
+	if err != nil {
+		return err
 	}
-	i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+	i, err := strconv.ParseInt(value, base, field.Type().Bits())
 	if err != nil {
 		return err
 	}
@@ -311,8 +536,15 @@ func (e *Enviro) setIntField(field reflect.Value, value string) error {
 	return nil
 }
 
-func (e *Enviro) setUintField(field reflect.Value, value string) error {
-	u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+func (e *Enviro) setUintField(field reflect.Value, value string, opts Options) error {
+	if opts.Has("bytes") {
+		return e.setByteSizeField(field, value)
+	}
+	base, err := e.intBase(opts)
+	if err != nil {
+		return err
+	}
+	u, err := strconv.ParseUint(value, base, field.Type().Bits())
 	if err != nil {
 		return err
 	}
@@ -329,209 +561,174 @@ func (e *Enviro) setFloatField(field reflect.Value, value string) error {
 	return nil
 }
 
-func (e *Enviro) setBoolField(field reflect.Value, value string) error {
-	b, err := strconv.ParseBool(value)
+func (e *Enviro) setSliceField(field reflect.Value, value string, opts Options) error {
+	if e.isRawBytes(field.Type().Elem(), opts) {
+		return e.setBytesField(field, value, opts)
+	}
+
+	elements, err := e.splitList(field.Type().Elem(), value, opts)
 	if err != nil {
 		return err
 	}
-	field.SetBool(b)
+
+	slice := reflect.MakeSlice(field.Type(), len(elements), len(elements))
+	elemOpts := opts.nested()
+	for i, elem := range elements {
+		// Each element goes through the same pipeline as a scalar field, so pointers, ParseField
+		// implementations and nested slices are all handled the same way.
+		if err := e.setField(slice.Index(i), elem, elemOpts); err != nil {
+			return &elementError{index: strconv.Itoa(i), err: err}
+		}
+	}
+
+	field.Set(reflect.AppendSlice(field, slice))
 	return nil
 }
 
-func (e *Enviro) setSliceField(field reflect.Value, value, opt string) error {
-	elements := strings.Split(value, ",")
-	slice := reflect.MakeSlice(field.Type(), len(elements), len(elements))
+// splitList splits the value of a slice or an array into its elements. Elements that are themselves lists split
+// with the separator of a deeper level keep their quotes for that level, so that a quoted inner element may
+// contain the separator of any level, e.g. `a,"b;c";d` with `sep:;|,`.
+func (e *Enviro) splitList(elem reflect.Type, value string, opts Options) ([]string, error) {
+	if e.hasNestedList(elem, opts) {
+		return splitQuoted(value, opts.separator())
+	}
+	return splitElements(value, opts.separator())
+}
 
-	isPtr := field.Type().Elem().Kind() == reflect.Ptr
-	var elemTyp reflect.Type
-	if isPtr {
-		elemTyp = field.Type().Elem().Elem()
-	} else {
-		elemTyp = field.Type().Elem()
+// hasNestedList reports whether the elements of type elem are lists split with the separator of a deeper level,
+// declared with the `sep` option.
+func (e *Enviro) hasNestedList(elem reflect.Type, opts Options) bool {
+	if opts.depth+1 >= len(opts.seps) {
+		return false
+	}
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	switch elem.Kind() {
+	case reflect.Slice, reflect.Array:
+		return !e.isRawBytes(elem.Elem(), opts.nested())
+	case reflect.Map:
+		return true
 	}
+	return false
+}
 
-	if slice.Index(0).Addr().Type().Implements(parserType) || slice.Index(0).Type().Implements(parserType) {
-		for i, elem := range elements {
-			newVal := reflect.New(elemTyp).Elem()
-			parser := newVal.Addr().Interface().(ParseField)
-			if err := parser.ParseField(elem); err != nil {
-				return err
-			}
-			if isPtr {
-				slice.Index(i).Set(newVal.Addr())
-			} else {
-				slice.Index(i).Set(newVal)
-			}
+// elementError is the error of a slice or array element, or of a map value. The error of the environment variable
+// reports the element by its path, as Diff does, e.g. "failed to parse environment variable HOSTS[2]: ...".
+type elementError struct {
+	index string
+	err   error
+}
+
+func (e *elementError) Error() string {
+	return fmt.Sprintf("invalid element %s: %v", e.index, e.err)
+}
+
+func (e *elementError) Unwrap() error {
+	return e.err
+}
+
+// variableError returns the error of the environment variable key, followed by the path of the element that
+// failed, if any.
+func variableError(action, key string, err error) error {
+	path := key
+	for {
+		ee, ok := err.(*elementError)
+		if !ok {
+			break
 		}
-		field.Set(reflect.AppendSlice(field, slice))
-		return nil
+		path += "[" + ee.index + "]"
+		err = ee.err
 	}
+	return fmt.Errorf("failed to %s environment variable %s: %w", action, path, err)
+}
 
-	switch elemTyp.Kind() {
-	case reflect.String:
-		for i, elem := range elements {
-			newVal := reflect.New(elemTyp).Elem()
-			if err := e.setStringField(newVal, strings.TrimSpace(elem)); err != nil {
-				return err
-			}
-			if isPtr {
-				slice.Index(i).Set(newVal.Addr())
-			} else {
-				slice.Index(i).Set(newVal)
-			}
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		for i, elem := range elements {
-			newVal := reflect.New(elemTyp).Elem()
-			if err := e.setIntField(newVal, strings.TrimSpace(elem)); err != nil {
-				return err
-			}
-			if isPtr {
-				slice.Index(i).Set(newVal.Addr())
-			} else {
-				slice.Index(i).Set(newVal)
-			}
-		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if field.Type() == reflect.TypeOf(net.IP(nil)) {
-			ip := net.ParseIP(value)
-			if ip == nil {
-				return errors.New("invalid IP address")
-			}
-			field.Set(reflect.ValueOf(ip))
-			return nil
-		}
+func (e *Enviro) setArrayField(field reflect.Value, value string, opts Options) error {
+	if e.isRawBytes(field.Type().Elem(), opts) {
+		return e.setByteArrayField(field, value, opts)
+	}
 
-		if field.Type() == reflect.TypeOf([]net.HardwareAddr(nil)) {
-			addr, err := net.ParseMAC(value)
-			if err != nil {
-				return err
-			}
-			field.Set(reflect.ValueOf(addr))
-			return nil
-		}
+	elements, err := e.splitList(field.Type().Elem(), value, opts)
+	if err != nil {
+		return err
+	}
+	if len(elements) != field.Len() {
+		return fmt.Errorf("expected %d elements, got %d", field.Len(), len(elements))
+	}
 
-		for i, elem := range elements {
-			newVal := reflect.New(elemTyp).Elem()
-			if err := e.setUintField(newVal, strings.TrimSpace(elem)); err != nil {
-				return err
-			}
-			if isPtr {
-				slice.Index(i).Set(newVal.Addr())
-			} else {
-				slice.Index(i).Set(newVal)
-			}
-		}
-	case reflect.Float32, reflect.Float64:
-		for i, elem := range elements {
-			newVal := reflect.New(elemTyp).Elem()
-			if err := e.setFloatField(newVal, strings.TrimSpace(elem)); err != nil {
-				return err
-			}
-			if isPtr {
-				slice.Index(i).Set(newVal.Addr())
-			} else {
-				slice.Index(i).Set(newVal)
-			}
+	// Parse into a temporary array so that the field is left untouched on error.
+	array := reflect.New(field.Type()).Elem()
+	elemOpts := opts.nested()
+	for i, elem := range elements {
+		if err := e.setField(array.Index(i), elem, elemOpts); err != nil {
+			return &elementError{index: strconv.Itoa(i), err: err}
 		}
-	case reflect.Bool:
-		for i, elem := range elements {
-			newVal := reflect.New(elemTyp).Elem()
-			if err := e.setBoolField(newVal, strings.TrimSpace(elem)); err != nil {
-				return err
-			}
-			if isPtr {
-				slice.Index(i).Set(newVal.Addr())
-			} else {
-				slice.Index(i).Set(newVal)
-			}
-		}
-	case reflect.Slice:
-		for i, elem := range elements {
-			newVal := reflect.New(elemTyp).Elem()
-			if err := e.setSliceField(newVal, elem, opt); err != nil {
-				return err
-			}
-			if isPtr {
-				slice.Index(i).Set(newVal.Addr())
-			} else {
-				slice.Index(i).Set(newVal)
-			}
-		}
-	case reflect.Struct:
-		for i, elem := range elements {
-			newVal := reflect.New(elemTyp).Elem()
-			if err := e.setStructField(newVal, elem, opt); err != nil {
-				return err
-			}
-			if isPtr {
-				slice.Index(i).Set(newVal.Addr())
-			} else {
-				slice.Index(i).Set(newVal)
-			}
-		}
-	default:
-		return fmt.Errorf("unsupported slice element type: %s", elemTyp.String())
 	}
 
-	field.Set(reflect.AppendSlice(field, slice))
+	field.Set(array)
 	return nil
 }
 
-func (e *Enviro) setStructField(field reflect.Value, value, opt string) error {
-
-	switch field.Type() {
-	case reflect.TypeOf(time.Time{}):
-		format, location := parseTimeFormatTag(opt)
-		return e.setTimeField(field, value, format, location)
-	case reflect.TypeOf(time.Location{}):
-		loc, err := time.LoadLocation(value)
-		if err != nil {
-			return err
-		}
-		field.Set(reflect.ValueOf(*loc))
-		return nil
-	case reflect.TypeOf(url.URL{}):
-		u, err := url.Parse(value)
-		if err != nil {
-			return err
-		}
-		field.Set(reflect.ValueOf(*u))
-		return nil
-	case reflect.TypeOf(os.File{}):
-		flag, perm := parseFileFormatTag(opt)
-		return e.setFileField(field, value, flag, perm)
+func (e *Enviro) setStructField(field reflect.Value, value string, opts Options) error {
+	value, err := decodeBlob(value, opts)
+	if err != nil {
+		return err
 	}
 
-	switch opt {
-	case "json":
+	switch {
+	case opts.Has("json"):
 		return e.setJsonField(field, value)
-	case "yaml":
+	case opts.Has("yaml"):
 		return e.setYamlField(field, value)
 	}
 
-	if opt == "" {
-		opt = "-"
-	}
-	return fmt.Errorf("unsupported format %q for %s", opt, field.Type().String())
+	return fmt.Errorf("unsupported format %q for %s", opts, field.Type().String())
 }
 
-func (e *Enviro) setMapField(field reflect.Value, value, opt string) error {
-	switch opt {
-	case "json":
+func (e *Enviro) setMapField(field reflect.Value, value string, opts Options) error {
+	value, err := decodeBlob(value, opts)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.Has("json"):
 		return e.setJsonField(field, value)
-	case "yaml":
+	case opts.Has("yaml"):
 		return e.setYamlField(field, value)
 	}
 
-	if opt == "" {
-		opt = "-"
+	// Without an explicit format, a map is a list of key=value pairs, e.g. "a=1,b=2"
+	elements, err := splitElements(value, opts.separator())
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("unsupported format %q for %s", opt, field.Type().String())
+
+	m := reflect.MakeMapWithSize(field.Type(), len(elements))
+	keyOpts, elemOpts := opts.mapKey(), opts.nested()
+	for i, elem := range elements {
+		k, v, found := strings.Cut(elem, "=")
+		if !found {
+			return fmt.Errorf("invalid element %d: missing '=' in %q", i, elem)
+		}
+
+		key := reflect.New(field.Type().Key()).Elem()
+		if err := e.setField(key, strings.TrimSpace(k), keyOpts); err != nil {
+			return fmt.Errorf("invalid key %q: %w", k, err)
+		}
+		val := reflect.New(field.Type().Elem()).Elem()
+		if err := e.setField(val, strings.TrimSpace(v), elemOpts); err != nil {
+			return &elementError{index: strings.TrimSpace(k), err: err}
+		}
+		m.SetMapIndex(key, val)
+	}
+
+	field.Set(m)
+	return nil
 }
 
-func (e *Enviro) setTimeField(field reflect.Value, value, format, location string) error {
-	loc := time.UTC
+func (e *Enviro) setTimeField(field reflect.Value, value string, layouts []string, location string) error {
+	loc := e.location()
 	if location != "" {
 		var err error
 		loc, err = time.LoadLocation(location)
@@ -540,29 +737,21 @@ func (e *Enviro) setTimeField(field reflect.Value, value, format, location strin
 		}
 	}
 
-	if format != "" {
-		t, err := time.ParseInLocation(format, value, loc)
-		if err != nil {
-			return err
-		}
-		field.Set(reflect.ValueOf(t))
-		return nil
+	if len(layouts) == 0 {
+		layouts = e.timeLayouts
 	}
 
-	t, err := parseDateWith(value, timeFormats, loc)
-	if err != nil {
-		return err
+	var t time.Time
+	var err error
+	if len(layouts) == 0 {
+		t, err = parseDateWith(value, timeFormats, loc)
+	} else {
+		t, err = e.parseTimeWith(value, layouts, loc)
 	}
-	field.Set(reflect.ValueOf(t))
-	return nil
-}
-
-func (e *Enviro) setFileField(field reflect.Value, value string, flag int, perm os.FileMode) error {
-	f, err := os.OpenFile(value, flag, perm)
 	if err != nil {
 		return err
 	}
-	field.Set(reflect.ValueOf(*f))
+	field.Set(reflect.ValueOf(t))
 	return nil
 }
 
@@ -5,8 +5,15 @@
 package enviro
 
 import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -157,3 +164,265 @@ func TestParseEnvCustomType(t *testing.T) {
 		t.Errorf("Expected %s, got %s", expectedTime, config.StartTime.Time)
 	}
 }
+
+func TestParseEnvSliceSeparator(t *testing.T) {
+	type Config struct {
+		Hosts  []string     `enviro:"hosts" envopt:"sep:;"`
+		Matrix [][]int      `enviro:"matrix" envopt:"sep:;|,"`
+		Names  []string     `enviro:"names"`
+		Times  []CustomTime `enviro:"times"`
+	}
+
+	os.Setenv("HOSTS", "a.com; b.com ;c.com")
+	os.Setenv("MATRIX", "1,2; 3, 4")
+	os.Setenv("NAMES", `"Doe, John", Jane ," padded "`)
+	os.Setenv("TIMES", "2023-01-02T15:04:05Z, 2024-01-02T15:04:05Z")
+	defer func() {
+		os.Unsetenv("HOSTS")
+		os.Unsetenv("MATRIX")
+		os.Unsetenv("NAMES")
+		os.Unsetenv("TIMES")
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse slice environment variables: %s", err)
+	}
+
+	if expected := []string{"a.com", "b.com", "c.com"}; !reflect.DeepEqual(config.Hosts, expected) {
+		t.Errorf("Expected %v, got %v", expected, config.Hosts)
+	}
+	if expected := [][]int{{1, 2}, {3, 4}}; !reflect.DeepEqual(config.Matrix, expected) {
+		t.Errorf("Expected %v, got %v", expected, config.Matrix)
+	}
+	if expected := []string{"Doe, John", "Jane", " padded "}; !reflect.DeepEqual(config.Names, expected) {
+		t.Errorf("Expected %q, got %q", expected, config.Names)
+	}
+	if len(config.Times) != 2 || config.Times[1].Year() != 2024 {
+		t.Errorf("Expected two trimmed custom time elements, got %v", config.Times)
+	}
+}
+
+func TestParseEnvNestedQuoting(t *testing.T) {
+	type Config struct {
+		Groups [][]string `enviro:"groups" envopt:"sep:;|,"`
+	}
+
+	// A quoted inner element may contain the separator of any level
+	os.Setenv("GROUPS", `a,"b;c"; "d,e" , f;"say ""hi"""`)
+	defer os.Unsetenv("GROUPS")
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse slice environment variables: %s", err)
+	}
+	expected := [][]string{{"a", "b;c"}, {"d,e", "f"}, {`say "hi"`}}
+	if !reflect.DeepEqual(config.Groups, expected) {
+		t.Errorf("Expected %q, got %q", expected, config.Groups)
+	}
+
+	config.Groups = [][]string{{"a;b", `x"y`}, {" padded ", "c,d"}, {"e"}}
+	env, err := e.Marshal(&config)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %s", err)
+	}
+	_, value, _ := strings.Cut(env[0], "=")
+	os.Setenv("GROUPS", value)
+	var parsed Config
+	if err := e.ParseEnv(&parsed); err != nil {
+		t.Fatalf("Failed to parse marshaled value %s: %s", value, err)
+	}
+	if !reflect.DeepEqual(parsed.Groups, config.Groups) {
+		t.Errorf("Expected %q, got %q from %s", config.Groups, parsed.Groups, value)
+	}
+
+	os.Setenv("GROUPS", `a,"b;c`)
+	if err := e.ParseEnv(&Config{}); err == nil {
+		t.Errorf("Expected an error for an unterminated quoted element")
+	}
+}
+
+func TestParseEnvArray(t *testing.T) {
+	type Config struct {
+		Ports [3]int       `enviro:"ports"`
+		IPs   [2]net.IP    `enviro:"ips"`
+		Key   [4]byte      `enviro:"key" envopt:"hex"`
+		Salt  [4]byte      `enviro:"salt" envopt:"base64"`
+		Grid  [2][2]string `enviro:"grid" envopt:"sep:;|,"`
+	}
+
+	os.Setenv("PORTS", "80, 443, 8080")
+	os.Setenv("IPS", "127.0.0.1,::1")
+	os.Setenv("KEY", "deadbeef")
+	os.Setenv("SALT", "AQIDBA")
+	os.Setenv("GRID", "a,b;c,d")
+	defer func() {
+		os.Unsetenv("PORTS")
+		os.Unsetenv("IPS")
+		os.Unsetenv("KEY")
+		os.Unsetenv("SALT")
+		os.Unsetenv("GRID")
+	}()
+
+	expected := Config{
+		Ports: [3]int{80, 443, 8080},
+		IPs:   [2]net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		Key:   [4]byte{0xde, 0xad, 0xbe, 0xef},
+		Salt:  [4]byte{1, 2, 3, 4},
+		Grid:  [2][2]string{{"a", "b"}, {"c", "d"}},
+	}
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse array environment variables: %s", err)
+	}
+
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	os.Setenv("PORTS", "80,443")
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for an array with too few elements")
+	}
+	os.Setenv("PORTS", "80,443,8080,9090")
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for an array with too many elements")
+	}
+}
+
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ":")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = strings.Split(value, ":")
+	return nil
+}
+
+type jsonLevel struct {
+	Name string
+}
+
+func (l *jsonLevel) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &l.Name)
+}
+
+func TestParseEnvUnmarshalerHooks(t *testing.T) {
+	type Config struct {
+		Addr   netip.Addr   `enviro:"addr"`
+		Level  *slog.Level  `enviro:"level"`
+		Levels []slog.Level `enviro:"levels"`
+		Path   listFlag     `enviro:"search_path"`
+		JSON   jsonLevel    `enviro:"json"`
+		Start  time.Time    `enviro:"start"`
+	}
+
+	os.Setenv("ADDR", "10.0.0.1")
+	os.Setenv("LEVEL", "warn")
+	os.Setenv("LEVELS", "debug, error")
+	os.Setenv("SEARCH_PATH", "/bin:/usr/bin")
+	os.Setenv("START", "2024-03-01")
+	defer func() {
+		os.Unsetenv("ADDR")
+		os.Unsetenv("LEVEL")
+		os.Unsetenv("LEVELS")
+		os.Unsetenv("SEARCH_PATH")
+		os.Unsetenv("JSON")
+		os.Unsetenv("START")
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+
+	if config.Addr != netip.MustParseAddr("10.0.0.1") {
+		t.Errorf("Expected 10.0.0.1, got %s", config.Addr)
+	}
+	if config.Level == nil || *config.Level != slog.LevelWarn {
+		t.Errorf("Expected WARN level, got %v", config.Level)
+	}
+	if expected := []slog.Level{slog.LevelDebug, slog.LevelError}; !reflect.DeepEqual(config.Levels, expected) {
+		t.Errorf("Expected %v, got %v", expected, config.Levels)
+	}
+	if expected := (listFlag{"/bin", "/usr/bin"}); !reflect.DeepEqual(config.Path, expected) {
+		t.Errorf("Expected %v, got %v", expected, config.Path)
+	}
+	if !config.Start.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected built-in time parsing to take precedence, got %s", config.Start)
+	}
+
+	os.Setenv("JSON", "info")
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected json.Unmarshaler to be ignored by default")
+	}
+
+	config = Config{}
+	e.UseJSONUnmarshaler(true)
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	if config.JSON.Name != "info" {
+		t.Errorf("Expected info, got %q", config.JSON.Name)
+	}
+}
+
+type scaledInt struct {
+	value int
+	key   string
+}
+
+func (s *scaledInt) ParseFieldWithOptions(value string, opts FieldOptions) error {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	scale := 1
+	if v, ok := opts.Options.Lookup("scale"); ok {
+		if scale, err = strconv.Atoi(v); err != nil {
+			return err
+		}
+	}
+	s.value = i * scale
+	s.key = opts.Key + "/" + opts.Field.Tag.Get("unit")
+	return nil
+}
+
+func (s *scaledInt) ParseField(string) error {
+	return errors.New("ParseField should not be called")
+}
+
+func TestParseEnvCustomTypeWithOptions(t *testing.T) {
+	type Config struct {
+		Timeout scaledInt   `enviro:"timeout" envopt:"scale:1000" unit:"ms"`
+		Sizes   []scaledInt `enviro:"sizes" envopt:"sep:; scale:2"`
+	}
+
+	os.Setenv("APP_TIMEOUT", "3")
+	os.Setenv("APP_SIZES", "1;2")
+	defer func() {
+		os.Unsetenv("APP_TIMEOUT")
+		os.Unsetenv("APP_SIZES")
+	}()
+
+	var config Config
+	e := New()
+	e.SetEnvPrefix("app")
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse custom type environment variable: %s", err)
+	}
+
+	if expected := (scaledInt{value: 3000, key: "APP_TIMEOUT/ms"}); config.Timeout != expected {
+		t.Errorf("Expected %+v, got %+v", expected, config.Timeout)
+	}
+	if expected := []scaledInt{{2, "APP_SIZES/"}, {4, "APP_SIZES/"}}; !reflect.DeepEqual(config.Sizes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config.Sizes)
+	}
+}
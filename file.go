@@ -0,0 +1,320 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// File is a file opened from a path given in an environment variable. Unlike *os.File, it can be opened lazily
+// and reopened, which makes it suitable for log files rotated by tools such as logrotate. The `file` option sets
+// the flags and permissions as for *os.File fields, and the `lazy` flag defers opening the file to its first use,
+// e.g. `envopt:"file:wo|create|append,0640,lazy"`. In lazy mode, the path is only validated when the
+// configuration is parsed: an existing file must not be a directory, and the parent directory of a missing file
+// must exist if the file is to be created.
+//
+// A File is safe for concurrent use.
+type File struct {
+	mu   sync.RWMutex
+	file *os.File
+	name string
+	flag int
+	perm os.FileMode
+}
+
+// Name returns the path of the file.
+func (f *File) Name() string {
+	return f.name
+}
+
+// File returns the underlying *os.File, opening it if needed. The returned file is closed when the File is
+// reopened or closed, so it should not be retained.
+func (f *File) File() (*os.File, error) {
+	f.mu.RLock()
+	file := f.file
+	f.mu.RUnlock()
+	if file != nil {
+		return file, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		file, err := os.OpenFile(f.name, f.flag, f.perm)
+		if err != nil {
+			return nil, err
+		}
+		f.file = file
+	}
+	return f.file, nil
+}
+
+// Write writes to the file, opening it if needed. It holds a read lock for the duration of the write, so that
+// concurrent writes are not interrupted by Reopen.
+func (f *File) Write(p []byte) (int, error) {
+	if _, err := f.File(); err != nil {
+		return 0, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	return f.file.Write(p)
+}
+
+// Reopen closes the file and opens it again with the same flags, typically after it has been moved by a log
+// rotation tool. A lazy file that has not been opened yet is left untouched.
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+
+	file, err := os.OpenFile(f.name, f.flag, f.perm)
+	if err != nil {
+		return err
+	}
+	old := f.file
+	f.file = file
+	return old.Close()
+}
+
+// Close closes the file. A closed File is opened again on its next use.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// Close closes the files held by the fields of config, a pointer to a struct previously parsed by Enviro, that
+// are bound to an environment variable: *File and *os.File fields, including in nested structs, slices, maps and
+// Dynamic fields. Files held by untagged fields are not the parser's, and are left open, as are the standard
+// streams. It returns the errors of all the files that failed to close.
+func Close(config any) error {
+	files, err := configFiles(config)
+	if err != nil {
+		return err
+	}
+	return closeAll(files)
+}
+
+var (
+	filePtrType   = reflect.TypeOf((*File)(nil))
+	osFilePtrType = reflect.TypeOf((*os.File)(nil))
+)
+
+// configFiles returns the files held by the fields of config bound to an environment variable, except the standard
+// streams.
+func configFiles(config any) ([]io.Closer, error) {
+	var files []io.Closer
+	seen := make(map[uintptr]struct{})
+	err := walkFields(config, "", func(bf boundField) error {
+		walkValues(unwrapDynamic(bf.value), seen, func(v reflect.Value) bool {
+			switch v.Type() {
+			case filePtrType:
+				files = append(files, v.Interface().(*File))
+			case osFilePtrType:
+				if f := v.Interface().(*os.File); f != os.Stdin && f != os.Stdout && f != os.Stderr {
+					files = append(files, f)
+				}
+			default:
+				return true
+			}
+			return false
+		})
+		return nil
+	})
+	return files, err
+}
+
+func closeAll(files []io.Closer) error {
+	var errs []error
+	for _, f := range files {
+		if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReopenOnSignal reopens every *File held by the fields of config bound to an environment variable when one of
+// the given signals is received, or SIGHUP if none is given. Reopen errors are reported to the optional onError
+// callback. The returned function stops listening for the signals.
+func ReopenOnSignal(config any, onError func(f *File, err error), sig ...os.Signal) (stop func()) {
+	var files []*File
+	closers, _ := configFiles(config)
+	for _, c := range closers {
+		if f, ok := c.(*File); ok {
+			files = append(files, f)
+		}
+	}
+
+	return onSignal(sig, func() {
+		for _, f := range files {
+			if err := f.Reopen(); err != nil && onError != nil {
+				onError(f, err)
+			}
+		}
+	})
+}
+
+// Close closes every file opened by e while parsing, including lazy files opened since. It is an alternative to
+// the Close function when the parsed configuration is not at hand, and makes Enviro an io.Closer.
+func (e *Enviro) Close() error {
+	e.mu.Lock()
+	files := make([]io.Closer, 0, len(e.files))
+	for f := range e.files {
+		files = append(files, f)
+	}
+	e.files = nil
+	e.mu.Unlock()
+
+	return closeAll(files)
+}
+
+// release closes the files of config, a configuration parsed by e that is discarded, and stops tracking them, so
+// that they are not retained until Enviro.Close.
+func (e *Enviro) release(config any) error {
+	files, err := configFiles(config)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	for _, f := range files {
+		delete(e.files, f)
+	}
+	e.mu.Unlock()
+	return closeAll(files)
+}
+
+func (e *Enviro) track(f io.Closer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.files == nil {
+		e.files = make(map[io.Closer]struct{})
+	}
+	e.files[f] = struct{}{}
+}
+
+func setFilePtr(e *Enviro, field reflect.Value, value string, opts Options) error {
+	flag, perm := parseFileFormatTag(opts)
+	f, err := os.OpenFile(value, flag, perm)
+	if err != nil {
+		return err
+	}
+	e.track(f)
+	field.Set(reflect.ValueOf(f))
+	return nil
+}
+
+func setEnviroFile(e *Enviro, field reflect.Value, value string, opts Options) error {
+	flag, perm := parseFileFormatTag(opts)
+	f := &File{name: value, flag: flag, perm: perm}
+	if isLazyFile(opts) {
+		if err := validateFilePath(value, flag); err != nil {
+			return err
+		}
+	} else if _, err := f.File(); err != nil {
+		return err
+	}
+	e.track(f)
+	field.Set(reflect.ValueOf(f))
+	return nil
+}
+
+func isLazyFile(opts Options) bool {
+	options, _ := opts.Lookup("file")
+	for _, part := range strings.Split(options, ",") {
+		if strings.TrimSpace(part) == "lazy" {
+			return true
+		}
+	}
+	return false
+}
+
+// validateFilePath checks that a file could be opened with the given flags, without opening it.
+func validateFilePath(name string, flag int) error {
+	info, err := os.Stat(name)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("invalid file %q: is a directory", name)
+		}
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) || flag&os.O_CREATE == 0 {
+		return err
+	}
+
+	dir, err := os.Stat(filepath.Dir(name))
+	if err != nil {
+		return err
+	}
+	if !dir.IsDir() {
+		return fmt.Errorf("invalid file %q: parent is not a directory", name)
+	}
+	return nil
+}
+
+// walkValues calls fn for v and, as long as fn returns true, for every value reachable from it through pointers,
+// interfaces, exported struct fields, slices, arrays and maps. The seen set holds the pointers already followed,
+// so that cycles are walked once.
+func walkValues(v reflect.Value, seen map[uintptr]struct{}, fn func(v reflect.Value) bool) {
+	if !v.IsValid() {
+		return
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface || v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil() {
+		return
+	}
+	if !fn(v) {
+		return
+	}
+	if v.Kind() == reflect.Ptr {
+		if _, ok := seen[v.Pointer()]; ok {
+			return
+		}
+		seen[v.Pointer()] = struct{}{}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		walkValues(v.Elem(), seen, fn)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkValues(v.Field(i), seen, fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkValues(v.Index(i), seen, fn)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			walkValues(iter.Value(), seen, fn)
+		}
+	}
+}
+
+// formatFile returns the name of an *os.File or *File, which is the path it was opened with.
+func formatFile(_ *Enviro, field reflect.Value, _ Options) (string, error) {
+	return field.Interface().(interface{ Name() string }).Name(), nil
+}
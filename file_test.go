@@ -0,0 +1,279 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	dir := t.TempDir()
+
+	type Config struct {
+		Input  *os.File   `enviro:"input"`
+		Logs   []*os.File `enviro:"logs" envopt:"file:wo|create|append,0600"`
+		Access *File      `enviro:"access" envopt:"file:create|wo|append,0600,lazy"`
+	}
+
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	access := filepath.Join(dir, "access.log")
+
+	env := map[string]string{
+		"INPUT":  input,
+		"LOGS":   filepath.Join(dir, "a.log") + "," + filepath.Join(dir, "b.log"),
+		"ACCESS": access,
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse file environment variables: %s", err)
+	}
+
+	buf := make([]byte, 5)
+	if _, err := config.Input.Read(buf); err != nil || string(buf) != "hello" {
+		t.Errorf("Expected to read %q, got %q (%v)", "hello", buf, err)
+	}
+	if len(config.Logs) != 2 {
+		t.Fatalf("Expected 2 log files, got %d", len(config.Logs))
+	}
+	if config.Access.Name() != access {
+		t.Errorf("Expected %s, got %s", access, config.Access.Name())
+	}
+	if _, err := os.Stat(access); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the lazy file not to be created at parse time, got %v", err)
+	}
+
+	if _, err := config.Access.Write([]byte("first\n")); err != nil {
+		t.Fatalf("Failed to write lazy file: %s", err)
+	}
+
+	// Simulate a log rotation.
+	if err := os.Rename(access, access+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Access.Reopen(); err != nil {
+		t.Fatalf("Failed to reopen file: %s", err)
+	}
+	if _, err := config.Access.Write([]byte("second\n")); err != nil {
+		t.Fatalf("Failed to write reopened file: %s", err)
+	}
+	if data, _ := os.ReadFile(access); string(data) != "second\n" {
+		t.Errorf("Expected %q, got %q", "second\n", data)
+	}
+
+	if err := Close(&config); err != nil {
+		t.Errorf("Failed to close files: %s", err)
+	}
+	if _, err := config.Input.Read(buf); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected a closed file, got %v", err)
+	}
+	if _, err := config.Logs[1].Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected a closed file, got %v", err)
+	}
+	// Files already closed are ignored.
+	if err := e.Close(); err != nil {
+		t.Errorf("Failed to close files: %s", err)
+	}
+
+	os.Setenv("ACCESS", filepath.Join(dir, "missing", "access.log"))
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for a lazy file in a missing directory")
+	}
+	os.Setenv("ACCESS", dir)
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for a lazy file that is a directory")
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("Failed to close files: %s", err)
+	}
+}
+
+func TestEnviroRelease(t *testing.T) {
+	type Config struct {
+		Input *os.File `enviro:"input"`
+		Log   *File    `enviro:"log" envopt:"file:wo|create,0600"`
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("INPUT", input)
+	os.Setenv("LOG", filepath.Join(dir, "app.log"))
+	defer os.Unsetenv("INPUT")
+	defer os.Unsetenv("LOG")
+
+	e := New()
+	var kept, discarded Config
+	if err := e.ParseEnv(&kept); err != nil {
+		t.Fatalf("Failed to parse file environment variables: %s", err)
+	}
+	if err := e.ParseEnv(&discarded); err != nil {
+		t.Fatalf("Failed to parse file environment variables: %s", err)
+	}
+	if len(e.files) != 4 {
+		t.Fatalf("Expected 4 tracked files, got %d", len(e.files))
+	}
+
+	if err := e.release(&discarded); err != nil {
+		t.Errorf("Failed to release files: %s", err)
+	}
+	if len(e.files) != 2 {
+		t.Errorf("Expected 2 tracked files, got %d", len(e.files))
+	}
+	if _, err := discarded.Input.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected a closed file, got %v", err)
+	}
+	if _, err := kept.Input.Read(make([]byte, 1)); err != nil {
+		t.Errorf("Expected the kept file to be open, got %v", err)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Errorf("Failed to close files: %s", err)
+	}
+	if _, err := kept.Input.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected a closed file, got %v", err)
+	}
+}
+
+func TestCloseBoundFiles(t *testing.T) {
+	type Config struct {
+		Input *os.File `enviro:"input"`
+		Owned *os.File
+		Self  any
+	}
+
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("INPUT", input)
+	defer os.Unsetenv("INPUT")
+
+	var config Config
+	if err := New().ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse file environment variables: %s", err)
+	}
+	owned, err := os.Open(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer owned.Close()
+	config.Owned = owned
+	config.Self = &config
+
+	// Only the files of the fields bound to an environment variable are closed
+	if err := Close(&config); err != nil {
+		t.Errorf("Failed to close files: %s", err)
+	}
+	if _, err := config.Input.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected a closed file, got %v", err)
+	}
+	if _, err := config.Owned.Read(make([]byte, 1)); err != nil {
+		t.Errorf("Expected the untagged file to be open, got %v", err)
+	}
+}
+
+type cyclicConfig struct {
+	Input  *os.File   `enviro:"input"`
+	Files  []*os.File `enviro:"files"`
+	Parent *cyclicConfig
+}
+
+func TestCloseCyclicConfig(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same file is held twice and the config points back to itself
+	config := &cyclicConfig{Input: f, Files: []*os.File{f}}
+	config.Parent = config
+	if err := Close(config); err != nil {
+		t.Errorf("Failed to close files: %s", err)
+	}
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected a closed file, got %v", err)
+	}
+}
+
+func TestParseEnvFileValue(t *testing.T) {
+	type Config struct {
+		Value os.File `enviro:"value"`
+	}
+
+	os.Setenv("VALUE", os.DevNull)
+	defer os.Unsetenv("VALUE")
+
+	e := New()
+	if err := e.ParseEnv(&Config{}); err == nil || !strings.Contains(err.Error(), "os.File value fields are not supported") {
+		t.Errorf("Expected an error for an os.File value field, got %v", err)
+	}
+	if len(e.files) != 0 {
+		t.Errorf("Expected no tracked file, got %d", len(e.files))
+	}
+}
+
+func TestReopenOnSignal(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+
+	type Config struct {
+		Log *File `enviro:"log" envopt:"file:create|wo|append,0600"`
+	}
+
+	os.Setenv("LOG", name)
+	defer os.Unsetenv("LOG")
+
+	var config Config
+	if err := New().ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse file environment variables: %s", err)
+	}
+	defer Close(&config)
+
+	stop := ReopenOnSignal(&config, nil, syscall.SIGUSR1)
+	defer stop()
+
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(name); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %s to be reopened after the signal", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
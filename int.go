@@ -0,0 +1,71 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// intBase returns the base to parse an integer with, either from the `base` option or the instance setting.
+func (e *Enviro) intBase(opts Options) (int, error) {
+	if b, ok := opts.Lookup("base"); ok {
+		base, err := strconv.Atoi(b)
+		if err != nil || base == 1 || base < 0 || base > 36 {
+			return 0, fmt.Errorf("invalid base %q", b)
+		}
+		return base, nil
+	}
+	if e.hasBase {
+		return e.base, nil
+	}
+	return 10, nil
+}
+
+// setFileMode parses an os.FileMode. Modes are written in octal, so a value without a base prefix such as "755"
+// is parsed in base 8 unless the field sets its own base.
+func setFileMode(e *Enviro, field reflect.Value, value string, opts Options) error {
+	base := 8
+	if opts.Has("base") {
+		var err error
+		if base, err = e.intBase(opts); err != nil {
+			return err
+		}
+	} else if hasIntPrefix(value) {
+		base = 0
+	}
+
+	u, err := strconv.ParseUint(value, base, 32)
+	if err != nil {
+		return fmt.Errorf("invalid file mode %q", value)
+	}
+	field.Set(reflect.ValueOf(os.FileMode(u)))
+	return nil
+}
+
+// hasIntPrefix reports whether value starts with a Go integer literal prefix other than a single leading zero.
+func hasIntPrefix(value string) bool {
+	value = strings.TrimLeft(value, "+-")
+	if len(value) < 2 || value[0] != '0' {
+		return false
+	}
+	switch value[1] {
+	case 'b', 'B', 'o', 'O', 'x', 'X':
+		return true
+	}
+	return false
+}
+
+// formatFileMode formats an os.FileMode in octal with a leading zero, e.g. "0755", unless the field sets a base.
+func formatFileMode(e *Enviro, field reflect.Value, opts Options) (string, error) {
+	mode := field.Uint()
+	if opts.Has("base") {
+		return e.formatUint(mode, opts)
+	}
+	return "0" + strconv.FormatUint(mode, 8), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvIntBase(t *testing.T) {
+	type Config struct {
+		Mask    uint8            `enviro:"mask" envopt:"base:0"`
+		Count   *int             `enviro:"count" envopt:"base:0"`
+		IDs     []uint16         `enviro:"ids" envopt:"base:16"`
+		Flags   map[string]int32 `enviro:"flags" envopt:"base:0"`
+		Mode    os.FileMode      `enviro:"mode"`
+		Modes   []os.FileMode    `enviro:"modes"`
+		Decimal os.FileMode      `enviro:"decimal" envopt:"base:10"`
+	}
+
+	os.Setenv("MASK", "0b1010_1010")
+	os.Setenv("COUNT", "1_000_000")
+	os.Setenv("IDS", "1f, FF")
+	os.Setenv("FLAGS", "read=0x1, write=0o2")
+	os.Setenv("MODE", "755")
+	os.Setenv("MODES", "0644, 0o600, 0x1ff")
+	os.Setenv("DECIMAL", "420")
+	defer func() {
+		os.Unsetenv("MASK")
+		os.Unsetenv("COUNT")
+		os.Unsetenv("IDS")
+		os.Unsetenv("FLAGS")
+		os.Unsetenv("MODE")
+		os.Unsetenv("MODES")
+		os.Unsetenv("DECIMAL")
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse integer environment variables: %s", err)
+	}
+
+	count := 1_000_000
+	expected := Config{
+		Mask:    0xaa,
+		Count:   &count,
+		IDs:     []uint16{0x1f, 0xff},
+		Flags:   map[string]int32{"read": 1, "write": 2},
+		Mode:    0755,
+		Modes:   []os.FileMode{0644, 0600, 0777},
+		Decimal: 0644,
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+}
+
+func TestParseEnvIntInstanceBase(t *testing.T) {
+	type Config struct {
+		Perm   uint32 `enviro:"perm"`
+		Amount int64  `enviro:"amount" envopt:"base:10"`
+	}
+
+	os.Setenv("PERM", "0x1F")
+	os.Setenv("AMOUNT", "0100")
+	defer func() {
+		os.Unsetenv("PERM")
+		os.Unsetenv("AMOUNT")
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for a hexadecimal literal in base 10")
+	}
+
+	e.SetIntBase(0)
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse integer environment variables: %s", err)
+	}
+	if expected := (Config{Perm: 0x1f, Amount: 100}); config != expected {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+// LogValuer returns a slog.LogValuer for config, which must be a pointer to a struct. See Enviro.LogValuer.
+func LogValuer(config any) slog.LogValuer {
+	return New().LogValuer(config)
+}
+
+// LogValuer returns a slog.LogValuer for config, which must be a pointer to a struct. The value is a group with an
+// attribute per field, keyed by the name in its enviro tag, and a nested group for each struct with the
+// `nested:prefix` tag, named after its prefix. Secret values are redacted, and fields without a value, like a nil
+// pointer, are logged as nil. Booleans, numbers, durations and times keep their type, while other values are
+// formatted as Marshal would. The configuration is read each time the value is resolved, so it must not be
+// modified concurrently.
+//
+//	logger.Info("starting", "config", enviro.LogValuer(&cfg))
+func (e *Enviro) LogValuer(config any) slog.LogValuer {
+	return configValuer{e: e, config: config}
+}
+
+// LogConfig logs config in a single record at the info level. See Enviro.LogConfig.
+func LogConfig(logger *slog.Logger, config any) error {
+	return New().LogConfig(logger, config)
+}
+
+// LogConfig logs config in a single record at the info level, with the message "configuration loaded" and the
+// configuration under the "config" attribute, as described by LogValuer. If enabled with SetLogDefaults, the
+// record also has a "defaults" attribute listing the variables whose value comes from their default, according
+// to the current environment. A nil logger logs to slog.Default.
+func (e *Enviro) LogConfig(logger *slog.Logger, config any) error {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	value, defaults, err := e.logValue(config)
+	if err != nil {
+		return err
+	}
+
+	attrs := []slog.Attr{{Key: "config", Value: value}}
+	if e.logDefaults {
+		if defaults == nil {
+			defaults = []string{}
+		}
+		attrs = append(attrs, slog.Any("defaults", defaults))
+	}
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "configuration loaded", attrs...)
+	return nil
+}
+
+type configValuer struct {
+	e      *Enviro
+	config any
+}
+
+// LogValue implements slog.LogValuer.
+func (v configValuer) LogValue() slog.Value {
+	value, _, err := v.e.logValue(v.config)
+	if err != nil {
+		return slog.AnyValue(err)
+	}
+	return value
+}
+
+// logGroup is a group of attributes under construction, whose items are either an attribute or a nested group,
+// in field order.
+type logGroup struct {
+	name  string
+	items []logItem
+}
+
+type logItem struct {
+	attr  slog.Attr
+	group *logGroup
+}
+
+// child returns the nested group with the given name, reusing the last item if it is that group, so that fields
+// are kept in order.
+func (g *logGroup) child(name string) *logGroup {
+	if n := len(g.items); n > 0 && g.items[n-1].group != nil && g.items[n-1].group.name == name {
+		return g.items[n-1].group
+	}
+	child := &logGroup{name: name}
+	g.items = append(g.items, logItem{group: child})
+	return child
+}
+
+func (g *logGroup) value() slog.Value {
+	attrs := make([]slog.Attr, 0, len(g.items))
+	for _, item := range g.items {
+		if item.group != nil {
+			attrs = append(attrs, slog.Attr{Key: item.group.name, Value: item.group.value()})
+			continue
+		}
+		attrs = append(attrs, item.attr)
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// logValue returns the slog value of config and the variables whose value comes from their default.
+func (e *Enviro) logValue(config any) (slog.Value, []string, error) {
+	lookup := e.lookupFunc()
+	var root logGroup
+	var defaults []string
+	err := walkFields(config, e.prefix, func(bf boundField) error {
+		group := &root
+		for _, name := range bf.groups {
+			group = group.child(name)
+		}
+		group.items = append(group.items, logItem{attr: slog.Attr{Key: bf.tagKey, Value: e.fieldLogValue(bf)}})
+
+		source := valueSource(bf, func(key string) (string, string, bool) {
+			value, ok := lookup(key)
+			return value, SourceEnv, ok
+		})
+		if source == SourceDefault {
+			defaults = append(defaults, bf.key)
+		}
+		return nil
+	})
+	if err != nil {
+		return slog.Value{}, nil, err
+	}
+	return root.value(), defaults, nil
+}
+
+func (e *Enviro) fieldLogValue(bf boundField) slog.Value {
+	formatted, err := e.formatField(bf.value, bf.opts)
+	if errors.Is(err, errUnset) {
+		return slog.AnyValue(nil)
+	}
+	if bf.secret {
+		return slog.StringValue(redacted)
+	}
+	if err != nil {
+		return slog.StringValue(unformattable(bf.value))
+	}
+
+	field := unwrapDynamic(bf.value)
+	for field.Kind() == reflect.Ptr && !field.IsNil() {
+		field = field.Elem()
+	}
+	switch field.Type() {
+	case reflect.TypeOf(time.Duration(0)):
+		return slog.DurationValue(time.Duration(field.Int()))
+	case reflect.TypeOf(time.Time{}):
+		return slog.TimeValue(field.Interface().(time.Time))
+	}
+	if e.hasFormatter(field.Type()) || isEnumType(field.Type(), bf.opts) {
+		return slog.StringValue(formatted)
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		return slog.BoolValue(field.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return slog.Int64Value(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return slog.Uint64Value(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		return slog.Float64Value(field.Float())
+	}
+	return slog.StringValue(formatted)
+}
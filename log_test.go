@@ -0,0 +1,120 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLogConfig(t *testing.T) {
+	type Database struct {
+		URL      *url.URL `enviro:"url"`
+		Password string   `enviro:"password,secret"`
+		Pool     int      `enviro:"pool" envdefault:"4"`
+	}
+
+	type Server struct {
+		Port    uint16        `enviro:"port"`
+		Timeout time.Duration `enviro:"timeout" envdefault:"5s"`
+	}
+
+	type Config struct {
+		Debug    bool            `enviro:"debug"`
+		Ratio    float64         `enviro:"ratio"`
+		Hosts    []string        `enviro:"hosts"`
+		Retries  *int            `enviro:"retries"`
+		Database Database        `enviro:"nested:db"`
+		Server   *Server         `enviro:"nested:server"`
+		Level    Dynamic[string] `enviro:"level" envdefault:"info"`
+	}
+
+	os.Setenv("APP_DEBUG", "true")
+	os.Setenv("APP_RATIO", "0.5")
+	os.Setenv("APP_HOSTS", "a,b")
+	os.Setenv("APP_DB_URL", "postgres://db:5432/app")
+	os.Setenv("APP_DB_PASSWORD", "s3cr3t")
+	os.Setenv("APP_SERVER_PORT", "8080")
+	defer os.Unsetenv("APP_DEBUG")
+	defer os.Unsetenv("APP_RATIO")
+	defer os.Unsetenv("APP_HOSTS")
+	defer os.Unsetenv("APP_DB_URL")
+	defer os.Unsetenv("APP_DB_PASSWORD")
+	defer os.Unsetenv("APP_SERVER_PORT")
+
+	e := New()
+	e.SetEnvPrefix("APP")
+	e.SetLogDefaults(true)
+	var cfg Config
+	if err := e.ParseEnv(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	if err := e.LogConfig(logger, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"level": "INFO",
+		"msg":   "configuration loaded",
+		"config": map[string]any{
+			"debug":   true,
+			"ratio":   0.5,
+			"hosts":   "a,b",
+			"retries": nil,
+			"db": map[string]any{
+				"url":      "postgres://db:5432/app",
+				"password": "[REDACTED]",
+				"pool":     float64(4),
+			},
+			"server": map[string]any{
+				"port":    float64(8080),
+				"timeout": float64(5 * time.Second),
+			},
+			"level": "info",
+		},
+		"defaults": []any{"APP_DB_POOL", "APP_SERVER_TIMEOUT", "APP_LEVEL"},
+	}
+	if !reflect.DeepEqual(record, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, record)
+	}
+}
+
+func TestLogValuer(t *testing.T) {
+	type Config struct {
+		Name string `enviro:"name"`
+		Key  string `enviro:"key,secret"`
+	}
+
+	cfg := Config{Name: "app", Key: "s3cr3t"}
+	value := LogValuer(&cfg).LogValue()
+	expected := []slog.Attr{slog.String("name", "app"), slog.String("key", "[REDACTED]")}
+	if attrs := value.Group(); !reflect.DeepEqual(attrs, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, attrs)
+	}
+
+	if err := LogConfig(slog.Default(), cfg); err == nil {
+		t.Errorf("Expected an error for a non pointer config")
+	}
+}
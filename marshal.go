@@ -0,0 +1,314 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatField is the counterpart of ParseField, used by Marshal to format a value so that ParseField can parse
+// it back. Types implementing ParseField should implement FormatField as well, otherwise Marshal falls back to
+// encoding.TextMarshaler and fmt.Stringer.
+type FormatField interface {
+	// FormatField returns the string representation of the receiver.
+	FormatField() (string, error)
+}
+
+var (
+	formatterType     = reflect.TypeOf((*FormatField)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	errUnset          = errors.New("unset value")
+)
+
+// Marshal is the inverse of ParseEnv: it returns the environment variables, in the KEY=VALUE form of os.Environ,
+// that ParseEnv would parse into config. It uses the prefix set on the Enviro instance, and the same nested names,
+// separators and formats as parsing, so that the result can be passed to a child process with exec.Cmd.Env or
+// saved as a snapshot of the effective configuration.
+//
+// Nil pointers, slices and maps, as well as false presence flags, are omitted, as ParseEnv leaves them unset when
+// the variable is missing. Some values can't be formatted, such as a *x509.CertPool or a PEM file given by its
+// path, and result in an error.
+func (e *Enviro) Marshal(config any) ([]string, error) {
+	return e.MarshalWithPrefix(config, e.prefix)
+}
+
+// MarshalWithPrefix is like Marshal but uses the given prefix instead of the one set on the Enviro instance.
+func (e *Enviro) MarshalWithPrefix(config any, prefix string) ([]string, error) {
+	var env []string
+	err := walkFields(config, prefix, func(bf boundField) error {
+		value, err := e.formatField(bf.value, bf.opts)
+		if errors.Is(err, errUnset) {
+			return nil
+		}
+		if err != nil {
+			return variableError("marshal", bf.key, err)
+		}
+		env = append(env, bf.key+"="+value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// formatField returns the string representation of field, following the same precedence as setField: formatters
+// registered for the field type come first, then those of built-in types matched on the exact field type, enums,
+// FormatField, other built-in types and the standard marshaling interfaces, and finally the rules of the kind. It
+// returns errUnset for values that are represented by a missing variable.
+func (e *Enviro) formatField(field reflect.Value, opts Options) (string, error) {
+	field = unwrapDynamic(field)
+
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		if field.IsNil() {
+			return "", errUnset
+		}
+	}
+	if formatter, ok := e.lookupFormatter(field.Type()); ok {
+		return formatter(field)
+	}
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice:
+		if formatter, ok := builtinFormatters[field.Type()]; ok {
+			return formatter(e, field, opts)
+		}
+	}
+
+	target := field
+	if field.Kind() == reflect.Ptr {
+		target = field.Elem()
+	}
+	if !target.CanAddr() {
+		// Map values are not addressable, but methods with a pointer receiver need an address.
+		v := reflect.New(target.Type()).Elem()
+		v.Set(target)
+		target = v
+	}
+
+	if formatter, ok := e.lookupFormatter(target.Type()); ok {
+		return formatter(target)
+	}
+
+	switch target.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		table, err := lookupEnumTable(target.Type(), opts)
+		if err != nil {
+			return "", err
+		}
+		if table != nil {
+			return table.format(target)
+		}
+	}
+
+	ptr := target.Addr()
+	if ptr.Type().Implements(formatterType) {
+		return ptr.Interface().(FormatField).FormatField()
+	}
+
+	if formatter, ok := builtinFormatters[target.Type()]; ok {
+		return formatter(e, target, opts)
+	}
+
+	switch {
+	case ptr.Type().Implements(textMarshalerType):
+		b, err := ptr.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	case ptr.Type().Implements(flagValueType):
+		return ptr.Interface().(flag.Value).String(), nil
+	case ptr.Type().Implements(jsonMarshalerType) && (e.jsonUnmarshaler || opts.Has("json")):
+		b, err := ptr.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return "", err
+		}
+		return encodeBlob(string(b), opts), nil
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		return target.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.formatInt(target.Int(), opts)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return e.formatUint(target.Uint(), opts)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(target.Float(), 'g', -1, target.Type().Bits()), nil
+	case reflect.Bool:
+		if isPresenceFlag(opts) && !target.Bool() {
+			return "", errUnset
+		}
+		return strconv.FormatBool(target.Bool()), nil
+	case reflect.Struct:
+		return formatBlob(target, opts)
+	case reflect.Slice, reflect.Array:
+		return e.formatSliceField(target, opts)
+	case reflect.Map:
+		return e.formatMapField(target, opts)
+	}
+	return "", errors.New("unsupported field type")
+}
+
+func (e *Enviro) formatInt(i int64, opts Options) (string, error) {
+	if opts.Has("bytes") && i >= 0 {
+		return ByteSize(i).String(), nil
+	}
+	base, err := e.intBase(opts)
+	if err != nil {
+		return "", err
+	}
+	if base == 0 {
+		base = 10
+	}
+	return strconv.FormatInt(i, base), nil
+}
+
+func (e *Enviro) formatUint(u uint64, opts Options) (string, error) {
+	if opts.Has("bytes") {
+		return ByteSize(u).String(), nil
+	}
+	base, err := e.intBase(opts)
+	if err != nil {
+		return "", err
+	}
+	if base == 0 {
+		base = 10
+	}
+	return strconv.FormatUint(u, base), nil
+}
+
+func (e *Enviro) formatSliceField(field reflect.Value, opts Options) (string, error) {
+	if e.isRawBytes(field.Type().Elem(), opts) {
+		b := make([]byte, field.Len())
+		for i := range b {
+			b[i] = byte(field.Index(i).Uint())
+		}
+		return encodeBytes(b, opts), nil
+	}
+
+	sep := opts.separator()
+	elemOpts := opts.nested()
+	nested := e.hasNestedList(field.Type().Elem(), opts)
+	elements := make([]string, field.Len())
+	for i := range elements {
+		elem, err := e.formatField(field.Index(i), elemOpts)
+		if errors.Is(err, errUnset) {
+			return "", &elementError{index: strconv.Itoa(i), err: errors.New("nil value")}
+		}
+		if err != nil {
+			return "", &elementError{index: strconv.Itoa(i), err: err}
+		}
+		if nested {
+			// The elements of the deeper level are quoted as needed
+			elements[i] = elem
+		} else {
+			elements[i] = quoteElement(elem, opts.separators()...)
+		}
+	}
+	return strings.Join(elements, sep), nil
+}
+
+func (e *Enviro) formatMapField(field reflect.Value, opts Options) (string, error) {
+	if opts.Has("json") || opts.Has("yaml") {
+		return formatBlob(field, opts)
+	}
+
+	sep := opts.separator()
+	keyOpts, elemOpts := opts.mapKey(), opts.nested()
+	elements := make([]string, 0, field.Len())
+	iter := field.MapRange()
+	for iter.Next() {
+		k, err := e.formatField(iter.Key(), keyOpts)
+		if err != nil {
+			return "", fmt.Errorf("invalid key %v: %w", iter.Key().Interface(), err)
+		}
+		if strings.Contains(k, "=") {
+			return "", fmt.Errorf("invalid key %q: contains '='", k)
+		}
+		v, err := e.formatField(iter.Value(), elemOpts)
+		if err != nil && !errors.Is(err, errUnset) {
+			return "", &elementError{index: k, err: err}
+		}
+		elements = append(elements, quoteElement(k+"="+v, opts.separators()...))
+	}
+	// Map iteration order is random, sorting keeps the output stable
+	sort.Strings(elements)
+	return strings.Join(elements, sep), nil
+}
+
+// formatBlob marshals a struct or a map in the format given by the `json` or `yaml` option.
+func formatBlob(field reflect.Value, opts Options) (string, error) {
+	var b []byte
+	var err error
+	switch {
+	case opts.Has("json"):
+		b, err = json.Marshal(field.Interface())
+	case opts.Has("yaml"):
+		b, err = yaml.Marshal(field.Interface())
+		b = []byte(strings.TrimSuffix(string(b), "\n"))
+	default:
+		return "", fmt.Errorf("unsupported format %q for %s", opts, field.Type().String())
+	}
+	if err != nil {
+		return "", err
+	}
+	return encodeBlob(string(b), opts), nil
+}
+
+// quoteElement quotes a slice element following the rules of splitElements, when the element would otherwise be
+// split, trimmed or dropped. seps holds the separator of the element's level followed by those of the outer
+// levels, which split their elements with splitQuoted: the element is quoted if it contains any of them, or a
+// quote that would be unbalanced.
+func quoteElement(s string, seps ...string) string {
+	trimmed := strings.TrimSpace(seps[0]) != ""
+	quote := s == "" || strings.HasPrefix(strings.TrimSpace(s), `"`) || (trimmed && strings.TrimSpace(s) != s)
+	quote = quote || (len(seps) > 1 && strings.Contains(s, `"`))
+	for _, sep := range seps {
+		quote = quote || strings.Contains(s, sep)
+	}
+	if quote {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
+
+// encodeBytes is the inverse of decodeBytes.
+func encodeBytes(b []byte, opts Options) string {
+	switch {
+	case opts.Has("hex"):
+		return hex.EncodeToString(b)
+	case opts.Has("base64"):
+		return base64.StdEncoding.EncodeToString(b)
+	case opts.Has("base64url"):
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	return string(b)
+}
+
+// encodeBlob is the inverse of decodeBlob.
+func encodeBlob(s string, opts Options) string {
+	return encodeBytes([]byte(s), opts)
+}
+
+// formatStringer formats the built-in types whose String method returns a value their setter accepts.
+func formatStringer(_ *Enviro, field reflect.Value, _ Options) (string, error) {
+	if field.Kind() != reflect.Ptr && field.CanAddr() && field.Addr().Type().Implements(stringerType) {
+		field = field.Addr()
+	}
+	return field.Interface().(fmt.Stringer).String(), nil
+}
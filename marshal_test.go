@@ -0,0 +1,144 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type celsius float64
+
+func (c *celsius) ParseField(value string) error {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(value, "C"), 64)
+	*c = celsius(f)
+	return err
+}
+
+func (c *celsius) FormatField() (string, error) {
+	return strconv.FormatFloat(float64(*c), 'f', -1, 64) + "C", nil
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	type Settings struct {
+		Name  string `json:"name" yaml:"name"`
+		Level int    `json:"level" yaml:"level"`
+	}
+
+	type Proxy struct {
+		URL     *url.URL      `enviro:"url"`
+		Timeout time.Duration `enviro:"timeout" envopt:"unit:s"`
+	}
+
+	type Config struct {
+		Name     string            `enviro:"name"`
+		Mask     uint32            `enviro:"mask" envopt:"base:16"`
+		Ratio    float64           `enviro:"ratio"`
+		Debug    bool              `enviro:"debug"`
+		Verbose  bool              `enviro:"verbose" envopt:"bool:presence"`
+		Start    time.Time         `enviro:"start"`
+		Day      time.Time         `enviro:"day" envopt:"time:2006-01-02"`
+		Expiry   time.Time         `enviro:"expiry" envopt:"time:unixmilli"`
+		Hosts    []string          `enviro:"hosts"`
+		Matrix   [][]int           `enviro:"matrix" envopt:"sep:;|,"`
+		Limits   map[string]int    `enviro:"limits"`
+		Settings Settings          `enviro:"settings" envopt:"json"`
+		Labels   map[string]string `enviro:"labels" envopt:"yaml base64"`
+		Key      []byte            `enviro:"key" envopt:"hex"`
+		Salt     [4]byte           `enviro:"salt"`
+		Level    int               `enviro:"level" envopt:"enum:low,normal,high"`
+		Perm     uint8             `enviro:"perm" envopt:"flags:read,write,exec"`
+		Retries  *int              `enviro:"retries"`
+		Missing  *int              `enviro:"missing"`
+		Size     ByteSize          `enviro:"size"`
+		Mode     os.FileMode       `enviro:"mode"`
+		Addr     netip.Addr        `enviro:"addr"`
+		Listen   HostPort          `enviro:"listen"`
+		Data     Path              `enviro:"data"`
+		Temp     celsius           `enviro:"temp"`
+		Proxy    Proxy             `enviro:"nested:proxy"`
+	}
+
+	retries := 3
+	expected := Config{
+		Name:     "app",
+		Mask:     0xff,
+		Ratio:    0.25,
+		Debug:    true,
+		Start:    time.Date(2024, 3, 1, 12, 30, 0, 500, time.UTC),
+		Day:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Expiry:   time.UnixMilli(1700000000123).UTC(),
+		Hosts:    []string{"a.com", "b,c", `"quoted"`, " padded ", ""},
+		Matrix:   [][]int{{1, 2}, {3}},
+		Limits:   map[string]int{"read": 10, "write": 5},
+		Settings: Settings{Name: "a, b", Level: 2},
+		Labels:   map[string]string{"team": "core"},
+		Key:      []byte{0xde, 0xad},
+		Salt:     [4]byte{'a', 'b', 'c', 'd'},
+		Level:    2,
+		Perm:     5,
+		Retries:  &retries,
+		Size:     512 * MiB,
+		Mode:     0750,
+		Addr:     netip.MustParseAddr("::1"),
+		Listen:   HostPort{Host: "localhost", Port: 8080},
+		Data:     "/var/lib/app",
+		Temp:     21.5,
+		Proxy: Proxy{
+			URL:     &url.URL{Scheme: "https", Host: "proxy.example.com", Path: "/"},
+			Timeout: 90 * time.Second,
+		},
+	}
+
+	e := New()
+	e.SetEnvPrefix("APP")
+	env, err := e.Marshal(&expected)
+	if err != nil {
+		t.Fatalf("Failed to marshal config: %s", err)
+	}
+
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "APP_MISSING=") || strings.HasPrefix(kv, "APP_VERBOSE=") {
+			t.Errorf("Expected unset values to be omitted, got %s", kv)
+		}
+		k, v, _ := strings.Cut(kv, "=")
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	for _, kv := range []string{"APP_PROXY_TIMEOUT=90", "APP_MODE=0750", "APP_PERM=read|exec", "APP_SIZE=512Mi", "APP_MASK=ff"} {
+		if !contains(env, kv) {
+			t.Errorf("Expected %s in %q", kv, env)
+		}
+	}
+
+	var config Config
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse marshaled config: %s", err)
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	if _, err := e.Marshal(&struct {
+		Level int `enviro:"level" envopt:"enum:low,high"`
+	}{Level: 5}); err == nil {
+		t.Errorf("Expected an error for a value without an enum name")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// HostPort is a network address of the form "host:port", where host is a host name or an IP address. The port
+// may be omitted from the environment variable if the field sets a default port with the `port` option, e.g.
+// `envopt:"port:8080"`. An empty host, as in ":8080", is valid and usually means all interfaces.
+type HostPort struct {
+	Host string
+	Port uint16
+}
+
+// String returns the address in the "host:port" form, enclosing IPv6 hosts in square brackets.
+func (hp HostPort) String() string {
+	return net.JoinHostPort(hp.Host, strconv.FormatUint(uint64(hp.Port), 10))
+}
+
+func setIP(_ *Enviro, field reflect.Value, value string, _ Options) error {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return fmt.Errorf("invalid IP address %q", value)
+	}
+	field.Set(reflect.ValueOf(ip))
+	return nil
+}
+
+func setIPNet(_ *Enviro, field reflect.Value, value string, _ Options) error {
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR address %q", value)
+	}
+	field.Set(reflect.ValueOf(*ipNet))
+	return nil
+}
+
+func setHardwareAddr(_ *Enviro, field reflect.Value, value string, _ Options) error {
+	addr, err := net.ParseMAC(value)
+	if err != nil {
+		return fmt.Errorf("invalid MAC address %q", value)
+	}
+	field.Set(reflect.ValueOf(addr))
+	return nil
+}
+
+func setAddr(_ *Enviro, field reflect.Value, value string, _ Options) error {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return fmt.Errorf("invalid IP address %q", value)
+	}
+	field.Set(reflect.ValueOf(addr))
+	return nil
+}
+
+func setPrefix(_ *Enviro, field reflect.Value, value string, _ Options) error {
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return fmt.Errorf("invalid CIDR prefix %q", value)
+	}
+	field.Set(reflect.ValueOf(prefix))
+	return nil
+}
+
+func setAddrPort(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	hp, err := parseHostPort(value, opts)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(hp.Host)
+	if err != nil {
+		return fmt.Errorf("invalid IP address %q in %q", hp.Host, value)
+	}
+	field.Set(reflect.ValueOf(netip.AddrPortFrom(addr, hp.Port)))
+	return nil
+}
+
+func setHostPort(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	hp, err := parseHostPort(value, opts)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(hp))
+	return nil
+}
+
+// parseHostPort splits value into a host and a port, falling back on the `port` option when the port is
+// missing.
+func parseHostPort(value string, opts Options) (HostPort, error) {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		// SplitHostPort doesn't tell a missing port apart from other errors, and rejects bare IPv6 addresses.
+		def, ok := opts.Lookup("port")
+		if !ok {
+			return HostPort{}, fmt.Errorf("invalid address %q: missing port", value)
+		}
+		host, port = value, def
+		if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1]
+		}
+		if strings.ContainsAny(host, "[]") || (strings.Contains(host, ":") && net.ParseIP(host) == nil) {
+			return HostPort{}, fmt.Errorf("invalid address %q", value)
+		}
+	}
+
+	if port == "" {
+		port, _ = opts.Lookup("port")
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return HostPort{}, fmt.Errorf("invalid port %q in %q: out of range", port, value)
+		}
+		return HostPort{}, fmt.Errorf("invalid port %q in %q", port, value)
+	}
+	return HostPort{Host: host, Port: uint16(p)}, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvNetworkTypes(t *testing.T) {
+	type Config struct {
+		IP       net.IP                `enviro:"ip"`
+		IPs      []net.IP              `enviro:"ips"`
+		Network  *net.IPNet            `enviro:"network"`
+		MAC      net.HardwareAddr      `enviro:"mac"`
+		MACs     []net.HardwareAddr    `enviro:"macs"`
+		Addr     netip.Addr            `enviro:"addr"`
+		Prefixes []netip.Prefix        `enviro:"prefixes"`
+		AddrPort *netip.AddrPort       `enviro:"addr_port" envopt:"port:53"`
+		Listen   HostPort              `enviro:"listen" envopt:"port:8080"`
+		Backends map[string]HostPort   `enviro:"backends"`
+		Peers    map[string]netip.Addr `enviro:"peers"`
+	}
+
+	env := map[string]string{
+		"IP":        "192.168.1.1",
+		"IPS":       "10.0.0.1, ::1",
+		"NETWORK":   "10.1.2.3/16",
+		"MAC":       "00:00:5e:00:53:01",
+		"MACS":      "00:00:5e:00:53:01,00:00:5e:00:53:02",
+		"ADDR":      "fe80::1",
+		"PREFIXES":  "10.0.0.0/8,fd00::/8",
+		"ADDR_PORT": "[::1]",
+		"LISTEN":    "localhost",
+		"BACKENDS":  "a=10.0.0.1:80, b=[::1]:443",
+		"PEERS":     "a=10.0.0.2",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse network environment variables: %s", err)
+	}
+
+	_, network, _ := net.ParseCIDR("10.1.0.0/16")
+	mac1, _ := net.ParseMAC("00:00:5e:00:53:01")
+	mac2, _ := net.ParseMAC("00:00:5e:00:53:02")
+	addrPort := netip.MustParseAddrPort("[::1]:53")
+	expected := Config{
+		IP:       net.ParseIP("192.168.1.1"),
+		IPs:      []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("::1")},
+		Network:  network,
+		MAC:      mac1,
+		MACs:     []net.HardwareAddr{mac1, mac2},
+		Addr:     netip.MustParseAddr("fe80::1"),
+		Prefixes: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")},
+		AddrPort: &addrPort,
+		Listen:   HostPort{Host: "localhost", Port: 8080},
+		Backends: map[string]HostPort{"a": {"10.0.0.1", 80}, "b": {"::1", 443}},
+		Peers:    map[string]netip.Addr{"a": netip.MustParseAddr("10.0.0.2")},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+	if s := config.Backends["b"].String(); s != "[::1]:443" {
+		t.Errorf("Expected [::1]:443, got %s", s)
+	}
+}
+
+func TestParseEnvNetworkErrors(t *testing.T) {
+	cases := []struct {
+		config any
+		value  string
+		want   string
+	}{
+		{config: &struct {
+			V net.IP `enviro:"v"`
+		}{}, value: "1.2.3", want: `invalid IP address "1.2.3"`},
+		{config: &struct {
+			V []netip.Prefix `enviro:"v"`
+		}{}, value: "10.0.0.0/8,10.0.0.0/33", want: `V[1]: invalid CIDR prefix "10.0.0.0/33"`},
+		{config: &struct {
+			V HostPort `enviro:"v"`
+		}{}, value: "localhost", want: `invalid address "localhost": missing port`},
+		{config: &struct {
+			V HostPort `enviro:"v"`
+		}{}, value: "localhost:70000", want: `invalid port "70000" in "localhost:70000": out of range`},
+		{config: &struct {
+			V net.HardwareAddr `enviro:"v"`
+		}{}, value: "00:00", want: `invalid MAC address "00:00"`},
+	}
+
+	defer os.Unsetenv("V")
+
+	for _, tc := range cases {
+		os.Setenv("V", tc.value)
+		err := New().ParseEnv(tc.config)
+		if err == nil || !strings.HasSuffix(err.Error(), tc.want) {
+			t.Errorf("Expected error ending with %q, got %v", tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const defaultSeparator = ","
+
+// knownDirectives lists the directive names built into Enviro. A whitespace separated token starts a new
+// directive if it begins with one of these names, or if it has the form `name:value` where name is made of
+// lowercase letters, digits, '-' and '_' (so that custom types may define their own directives). Any other
+// token is part of the preceding directive, which keeps layouts such as `envopt:"time:2006-01-02 15:04:05"`
+// working.
+var knownDirectives = map[string]struct{}{
+	"json":      {},
+	"yaml":      {},
+	"time":      {},
+	"file":      {},
+	"sep":       {},
+	"hex":       {},
+	"base64":    {},
+	"port":      {},
+	"bytes":     {},
+	"duration":  {},
+	"unit":      {},
+	"bool":      {},
+	"base":      {},
+	"enum":      {},
+	"flags":     {},
+	"base64url": {},
+	"raw":       {},
+	"pem":       {},
+	"url":       {},
+	"regexp":    {},
+	"path":      {},
+}
+
+type directive struct {
+	name  string
+	value string
+}
+
+// Options is the parsed representation of an `envopt` tag. A tag holds one or more directives
+// separated by whitespace, each directive being either a bare name (e.g. `json`) or a name and a
+// value separated by a colon (e.g. `sep:;`).
+//
+// Custom directives are best written as `name:value`: a bare name that is not a built-in directive
+// is considered part of the preceding directive unless it comes first.
+type Options struct {
+	field      reflect.StructField
+	key        string
+	raw        string
+	directives []directive
+	seps       []string
+	depth      int
+}
+
+func parseOptions(raw string) (Options, error) {
+	opts := Options{raw: raw}
+
+	start := -1
+	for i := 0; i <= len(raw); i++ {
+		if i < len(raw) && !isSpace(raw[i]) {
+			if (i == 0 || isSpace(raw[i-1])) && (start < 0 || isDirective(raw[i:])) {
+				if start >= 0 {
+					opts.directives = append(opts.directives, newDirective(strings.TrimSpace(raw[start:i])))
+				}
+				start = i
+			}
+			continue
+		}
+		if i == len(raw) && start >= 0 {
+			opts.directives = append(opts.directives, newDirective(strings.TrimSpace(raw[start:])))
+		}
+	}
+
+	if sep, ok := opts.Lookup("sep"); ok {
+		seps, err := parseSeparators(sep)
+		if err != nil {
+			return opts, err
+		}
+		opts.seps = seps
+	}
+
+	return opts, nil
+}
+
+// Lookup returns the value of the first directive with the given name and reports whether it was found.
+func (o Options) Lookup(name string) (string, bool) {
+	for _, d := range o.directives {
+		if d.name == name {
+			return d.value, true
+		}
+	}
+	return "", false
+}
+
+// Has reports whether the directive with the given name is present.
+func (o Options) Has(name string) bool {
+	_, ok := o.Lookup(name)
+	return ok
+}
+
+// separator returns the element separator for the current nesting level.
+func (o Options) separator() string {
+	if o.depth < len(o.seps) && o.seps[o.depth] != "" {
+		return o.seps[o.depth]
+	}
+	return defaultSeparator
+}
+
+// nested returns the options to apply to the elements of a slice parsed at the current level.
+// separators returns the separator of the current level followed by those of the outer levels.
+func (o Options) separators() []string {
+	seps := make([]string, 0, o.depth+1)
+	for ; o.depth >= 0; o.depth-- {
+		seps = append(seps, o.separator())
+	}
+	return seps
+}
+
+func (o Options) nested() Options {
+	o.depth++
+	return o
+}
+
+// mapKey returns the options to apply to the keys of a map parsed at the current level. The `enum` and `flags`
+// options describe the values of the map, so they don't apply to its keys.
+func (o Options) mapKey() Options {
+	return o.nested().without("enum", "flags")
+}
+
+// without returns the options without the given directives.
+func (o Options) without(names ...string) Options {
+	directives := make([]directive, 0, len(o.directives))
+	for _, d := range o.directives {
+		if !slices.Contains(names, d.name) {
+			directives = append(directives, d)
+		}
+	}
+	o.directives = directives
+	return o
+}
+
+// Raw returns the envopt tag as written in the struct field.
+func (o Options) Raw() string {
+	return o.raw
+}
+
+// String returns the raw envopt tag, or "-" if none was provided.
+func (o Options) String() string {
+	if o.raw == "" {
+		return "-"
+	}
+	return o.raw
+}
+
+func newDirective(s string) directive {
+	name, value, _ := strings.Cut(s, ":")
+	return directive{name: strings.TrimSpace(name), value: value}
+}
+
+func isDirective(s string) bool {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return r == ':' || unicode.IsSpace(r)
+	})
+	if end < 0 {
+		end = len(s)
+	}
+	name := s[:end]
+	if _, ok := knownDirectives[name]; ok {
+		return true
+	}
+	if end == len(s) || s[end] != ':' || name == "" || name[0] < 'a' || name[0] > 'z' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// parseSeparators parses the value of a `sep` directive. Each nesting level is separated by a "|", and
+// a backslash escapes the next character, so `sep:\|` splits on a pipe. The sequences `\s`, `\t` and
+// `\n` stand for a space, a tab and a new line.
+func parseSeparators(s string) ([]string, error) {
+	var seps []string
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '|':
+			seps = append(seps, sb.String())
+			sb.Reset()
+		case '\\':
+			if i+1 == len(s) {
+				return nil, errors.New("invalid separator: trailing backslash")
+			}
+			i++
+			switch s[i] {
+			case 's':
+				sb.WriteByte(' ')
+			case 't':
+				sb.WriteByte('\t')
+			case 'n':
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return append(seps, sb.String()), nil
+}
+
+// splitQuoted splits s around each instance of sep that is not enclosed in double quotes. Unlike splitElements,
+// the elements keep their quotes, so that they can be split again with the separator of a deeper level, where a
+// quoted element may contain any separator. Elements are trimmed of leading and trailing whitespace (unless the
+// separator is itself whitespace), and an empty or blank string yields no elements.
+func splitQuoted(s, sep string) ([]string, error) {
+	trim := strings.TrimSpace
+	if strings.TrimSpace(sep) == "" {
+		trim = func(s string) string { return s }
+	}
+
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var elems []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+			i++
+		case !quoted && strings.HasPrefix(s[i:], sep):
+			elems = append(elems, trim(s[start:i]))
+			i += len(sep)
+			start = i
+		default:
+			i++
+		}
+	}
+	if quoted {
+		return nil, errors.New("unterminated quoted element")
+	}
+	return append(elems, trim(s[start:])), nil
+}
+
+// splitElements splits s around each instance of sep following CSV quoting rules: an element enclosed
+// in double quotes may contain the separator, and a doubled quote inside a quoted element stands for a
+// single quote. Unquoted elements are trimmed of leading and trailing whitespace (unless the separator
+// is itself whitespace), while quoted elements are kept verbatim. An empty or blank string yields no
+// elements.
+func splitElements(s, sep string) ([]string, error) {
+	trim := strings.TrimSpace
+	if strings.TrimSpace(sep) == "" {
+		trim = func(s string) string { return s }
+	}
+
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var elems []string
+	for {
+		if rest := trim(s); strings.HasPrefix(rest, `"`) {
+			var sb strings.Builder
+			rest = rest[1:]
+			for {
+				i := strings.IndexByte(rest, '"')
+				if i < 0 {
+					return nil, errors.New("unterminated quoted element")
+				}
+				sb.WriteString(rest[:i])
+				rest = rest[i+1:]
+				if !strings.HasPrefix(rest, `"`) {
+					break
+				}
+				sb.WriteByte('"')
+				rest = rest[1:]
+			}
+			elems = append(elems, sb.String())
+
+			i := strings.Index(rest, sep)
+			if i < 0 {
+				i = len(rest)
+			}
+			if trim(rest[:i]) != "" {
+				return nil, fmt.Errorf("unexpected %q after quoted element", trim(rest[:i]))
+			}
+			if i == len(rest) {
+				return elems, nil
+			}
+			s = rest[i+len(sep):]
+			continue
+		}
+
+		i := strings.Index(s, sep)
+		if i < 0 {
+			return append(elems, trim(s)), nil
+		}
+		elems = append(elems, trim(s[:i]))
+		s = s[i+len(sep):]
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	opts, err := parseOptions(`sep:\||; time:2006-01-02 15:04:05,UTC json`)
+	if err != nil {
+		t.Fatalf("Failed to parse options: %s", err)
+	}
+
+	if layout, _ := opts.Lookup("time"); layout != "2006-01-02 15:04:05,UTC" {
+		t.Errorf("Expected time layout to span whitespace, got %q", layout)
+	}
+	if !opts.Has("json") {
+		t.Errorf("Expected json directive")
+	}
+	if sep := opts.separator(); sep != "|" {
+		t.Errorf("Expected separator %q, got %q", "|", sep)
+	}
+	if sep := opts.nested().separator(); sep != ";" {
+		t.Errorf("Expected nested separator %q, got %q", ";", sep)
+	}
+	if sep := opts.nested().nested().separator(); sep != defaultSeparator {
+		t.Errorf("Expected default separator, got %q", sep)
+	}
+}
+
+func TestSplitElements(t *testing.T) {
+	cases := []struct {
+		value   string
+		sep     string
+		want    []string
+		wantErr bool
+	}{
+		{value: "a, b ,c", sep: ",", want: []string{"a", "b", "c"}},
+		{value: `"a,b" , "say ""hi"""`, sep: ",", want: []string{"a,b", `say "hi"`}},
+		{value: "a  b", sep: " ", want: []string{"a", "", "b"}},
+		{value: "a::b", sep: "::", want: []string{"a", "b"}},
+		{value: "  ", sep: ",", want: nil},
+		{value: `"a`, sep: ",", wantErr: true},
+		{value: `"a"b,c`, sep: ",", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := splitElements(tc.value, tc.sep)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("splitElements(%q, %q): expected error", tc.value, tc.sep)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitElements(%q, %q): unexpected error: %s", tc.value, tc.sep, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitElements(%q, %q): expected %q, got %q", tc.value, tc.sep, tc.want, got)
+		}
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	cases := []struct {
+		value   string
+		sep     string
+		want    []string
+		wantErr bool
+	}{
+		{value: `a,"b;c" ; d`, sep: ";", want: []string{`a,"b;c"`, "d"}},
+		{value: `"say ""hi;"""; e`, sep: ";", want: []string{`"say ""hi;"""`, "e"}},
+		{value: "  ", sep: ";", want: nil},
+		{value: `"a;b`, sep: ";", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := splitQuoted(tc.value, tc.sep)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("splitQuoted(%q, %q): expected error", tc.value, tc.sep)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("splitQuoted(%q, %q): unexpected error: %s", tc.value, tc.sep, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("splitQuoted(%q, %q): expected %q, got %q", tc.value, tc.sep, tc.want, got)
+		}
+	}
+}
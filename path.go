@@ -0,0 +1,258 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Path is a filesystem path. Path fields, and string fields with the `path` option, are cleaned, have a leading
+// "~" replaced by the home directory and $VAR or ${VAR} references expanded, and are resolved relative to the
+// directory set with SetBaseDir. The `path` option accepts the following checks, separated by a comma:
+//
+//   - dir: the path must be a directory, if it exists.
+//   - file: the path must be a regular file, if it exists.
+//   - exists: the path must exist.
+//   - readable: the path must exist and be readable.
+//   - writable: the path must be writable or, if it does not exist, its parent directory must be.
+//   - mkdir=perm: a missing directory is created with the given octal permissions, as well as its parents. For
+//     a path that is not a directory, its parent directory is created.
+//
+// For example, `envopt:"path:dir,exists,writable"` or `envopt:"path:dir,mkdir=0750"`.
+type Path string
+
+// String returns the path.
+func (p Path) String() string {
+	return string(p)
+}
+
+type pathOptions struct {
+	dir      bool
+	file     bool
+	exists   bool
+	readable bool
+	writable bool
+	mkdir    bool
+	perm     os.FileMode
+}
+
+func parsePathOptions(opts Options) (pathOptions, error) {
+	var po pathOptions
+	options, _ := opts.Lookup("path")
+	for _, part := range strings.Split(options, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "":
+		case "dir":
+			po.dir = true
+		case "file":
+			po.file = true
+		case "exists":
+			po.exists = true
+		case "readable":
+			po.readable = true
+		case "writable":
+			po.writable = true
+		case "mkdir":
+			po.mkdir = true
+			po.perm = 0750
+			if value != "" {
+				perm, err := strconv.ParseUint(value, 8, 32)
+				if err != nil {
+					return po, fmt.Errorf("invalid mkdir permissions %q", value)
+				}
+				po.perm = os.FileMode(perm)
+			}
+		default:
+			return po, fmt.Errorf("unsupported path option %q", name)
+		}
+	}
+	if po.dir && po.file {
+		return po, errors.New("path options dir and file are mutually exclusive")
+	}
+	return po, nil
+}
+
+func setPath(e *Enviro, field reflect.Value, value string, opts Options) error {
+	po, err := parsePathOptions(opts)
+	if err != nil {
+		return err
+	}
+	name, err := e.resolvePath(value)
+	if err != nil {
+		return err
+	}
+	if err := e.checkPath(name, po); err != nil {
+		return err
+	}
+	field.SetString(name)
+	return nil
+}
+
+// resolvePath expands and cleans a path, and makes it relative to the base directory.
+func (e *Enviro) resolvePath(value string) (string, error) {
+	lookup := e.lookupFunc()
+	name := os.Expand(strings.TrimSpace(value), func(key string) string {
+		v, _ := lookup(key)
+		return v
+	})
+	if name == "~" || strings.HasPrefix(name, "~/") || strings.HasPrefix(name, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		name = home + name[1:]
+	}
+	if name == "" {
+		return "", nil
+	}
+	if e.baseDir != "" && !filepath.IsAbs(name) {
+		name = filepath.Join(e.baseDir, name)
+	}
+	return filepath.Clean(name), nil
+}
+
+func (e *Enviro) checkPath(name string, po pathOptions) error {
+	if name == "" {
+		if po.exists || po.readable || po.writable || po.mkdir {
+			return errors.New("empty path")
+		}
+		return nil
+	}
+
+	info, err := e.statPath(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if info == nil && po.mkdir {
+		dir := name
+		if !po.dir {
+			dir = filepath.Dir(name)
+		}
+		if err := e.mkdirAll(dir, po.perm); err != nil {
+			return err
+		}
+		if po.dir {
+			if info, err = e.statPath(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if info == nil {
+		if po.exists || po.readable {
+			return fmt.Errorf("path %q does not exist", name)
+		}
+		if po.writable {
+			parent, err := e.statPath(filepath.Dir(name))
+			if err != nil {
+				return fmt.Errorf("parent directory of %q: %w", name, err)
+			}
+			if !parent.IsDir() {
+				return fmt.Errorf("parent of %q is not a directory", name)
+			}
+			return e.checkWritable(filepath.Dir(name), parent)
+		}
+		return nil
+	}
+
+	if po.dir && !info.IsDir() {
+		return fmt.Errorf("path %q is not a directory", name)
+	}
+	if po.file && !info.Mode().IsRegular() {
+		return fmt.Errorf("path %q is not a regular file", name)
+	}
+	if po.readable {
+		if err := e.checkReadable(name, info); err != nil {
+			return err
+		}
+	}
+	if po.writable {
+		if err := e.checkWritable(name, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fsPath converts a path to the form expected by fs.FS: slash separated and unrooted.
+func fsPath(name string) string {
+	name = strings.TrimLeft(path.Clean(filepath.ToSlash(name)), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (e *Enviro) statPath(name string) (fs.FileInfo, error) {
+	if e.fsys != nil {
+		return fs.Stat(e.fsys, fsPath(name))
+	}
+	return os.Stat(name)
+}
+
+func (e *Enviro) checkReadable(name string, info fs.FileInfo) error {
+	if e.fsys != nil {
+		if info.Mode().Perm()&0444 == 0 {
+			return fmt.Errorf("path %q is not readable", name)
+		}
+		return nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("path %q is not readable: %w", name, err)
+	}
+	return f.Close()
+}
+
+// checkWritable reports whether a file or directory is writable. On the operating system filesystem, this is
+// tested by opening the file for writing, or by creating a temporary file in the directory. With a fs.FS set
+// with SetFS, only the permission bits are checked.
+func (e *Enviro) checkWritable(name string, info fs.FileInfo) error {
+	if e.fsys != nil {
+		if info.Mode().Perm()&0222 == 0 {
+			return fmt.Errorf("path %q is not writable", name)
+		}
+		return nil
+	}
+
+	if info.IsDir() {
+		f, err := os.CreateTemp(name, ".enviro-*")
+		if err != nil {
+			return fmt.Errorf("path %q is not writable: %w", name, err)
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("path %q is not writable: %w", name, err)
+	}
+	return f.Close()
+}
+
+func (e *Enviro) mkdirAll(name string, perm os.FileMode) error {
+	if e.fsys != nil {
+		mkdir, ok := e.fsys.(interface {
+			MkdirAll(name string, perm fs.FileMode) error
+		})
+		if !ok {
+			return fmt.Errorf("cannot create directory %q: filesystem does not support MkdirAll", name)
+		}
+		return mkdir.MkdirAll(fsPath(name), perm)
+	}
+	return os.MkdirAll(name, perm)
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseEnvPath(t *testing.T) {
+	type Config struct {
+		Data   Path   `enviro:"data" envopt:"path:dir,exists,writable"`
+		Config Path   `enviro:"config" envopt:"path:file,readable"`
+		Cache  string `enviro:"cache" envopt:"path:dir"`
+		Home   Path   `enviro:"app_home"`
+		Plugin *Path  `enviro:"plugin" envopt:"path:file"`
+	}
+
+	fsys := fstest.MapFS{
+		"srv/app/data":          {Mode: os.ModeDir | 0750},
+		"srv/app/config.yaml":   {Data: []byte("a: 1"), Mode: 0640},
+		"srv/app/plugins/a.so":  {Mode: 0755},
+		"srv/app/readonly":      {Mode: os.ModeDir | 0550},
+		"srv/app/private.yaml":  {Mode: 0200},
+		"srv/app/cache/entries": {Mode: 0640},
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+
+	env := map[string]string{
+		"APP_DIR":  "/srv/app",
+		"DATA":     "data/",
+		"CONFIG":   "$APP_DIR/config.yaml",
+		"CACHE":    "${APP_DIR}/./cache",
+		"APP_HOME": "~/.app",
+		"PLUGIN":   "plugins/../plugins/a.so",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	e.SetFS(fsys)
+	e.SetBaseDir("/srv/app")
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse path environment variables: %s", err)
+	}
+
+	plugin := Path("/srv/app/plugins/a.so")
+	expected := Config{
+		Data:   "/srv/app/data",
+		Config: "/srv/app/config.yaml",
+		Cache:  "/srv/app/cache",
+		Home:   Path(filepath.Join(home, ".app")),
+		Plugin: &plugin,
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"DATA", "missing"},
+		{"DATA", "config.yaml"},
+		{"DATA", "readonly"},
+		{"CONFIG", "data"},
+		{"CONFIG", "private.yaml"},
+		{"CONFIG", "missing.yaml"},
+		{"CACHE", "config.yaml"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			os.Setenv(tc.key, tc.value)
+			defer os.Setenv(tc.key, env[tc.key])
+			if err := e.ParseEnv(&config); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestParseEnvPathMkdir(t *testing.T) {
+	dir := t.TempDir()
+
+	type Config struct {
+		Spool Path `enviro:"spool" envopt:"path:dir,mkdir=0700,writable"`
+		Log   Path `enviro:"log" envopt:"path:file,mkdir,writable"`
+	}
+
+	os.Setenv("SPOOL", "var/spool")
+	os.Setenv("LOG", "var/log/app.log")
+	defer os.Unsetenv("SPOOL")
+	defer os.Unsetenv("LOG")
+
+	var config Config
+	e := New()
+	e.SetBaseDir(dir)
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse path environment variables: %s", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "var", "spool"))
+	if err != nil || !info.IsDir() || info.Mode().Perm() != 0700 {
+		t.Errorf("Expected a directory with mode 0700, got %v (%v)", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "var", "log")); err != nil {
+		t.Errorf("Expected the parent directory of the log file to be created: %s", err)
+	}
+	if _, err := os.Stat(string(config.Log)); !os.IsNotExist(err) {
+		t.Errorf("Expected the log file not to be created, got %v", err)
+	}
+
+	if err := New().ParseEnv(&struct {
+		Spool Path `enviro:"spool" envopt:"path:dir,mkdir=999"`
+	}{}); err == nil {
+		t.Errorf("Expected an error for invalid mkdir permissions")
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// CheckStatus is the outcome of a preflight check.
+type CheckStatus string
+
+const (
+	// CheckPassed means the check succeeded.
+	CheckPassed CheckStatus = "pass"
+	// CheckFailed means the check failed.
+	CheckFailed CheckStatus = "fail"
+	// CheckSkipped means the field has no value to check.
+	CheckSkipped CheckStatus = "skip"
+)
+
+// CheckResult is the result of a preflight check on one field.
+type CheckResult struct {
+	// Key is the environment variable name.
+	Key string `json:"key"`
+	// Field is the struct field name.
+	Field string `json:"field"`
+	// Check is the check as written in the envcheck tag, e.g. "bindable" or "scheme=https".
+	Check string `json:"check"`
+	// Value is the checked value, with URL passwords redacted.
+	Value string `json:"value,omitempty"`
+	// Status is the outcome of the check.
+	Status CheckStatus `json:"status"`
+	// Error describes the failure, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the result of the preflight checks of a configuration.
+type Report struct {
+	Results []CheckResult `json:"results"`
+}
+
+// OK reports whether no check failed.
+func (r *Report) OK() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed returns the results of the checks that failed.
+func (r *Report) Failed() []CheckResult {
+	var failed []CheckResult
+	for _, res := range r.Results {
+		if res.Status == CheckFailed {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns an error listing every failed check, or nil if all checks passed.
+func (r *Report) Err() error {
+	var errs []error
+	for _, res := range r.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %s: %s", res.Key, res.Check, res.Error))
+	}
+	return errors.Join(errs...)
+}
+
+// String formats the report as a table, one check per line, suitable for a `--check-config` command.
+func (r *Report) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for _, res := range r.Results {
+		line := strings.ToUpper(string(res.Status)) + "\t" + res.Key + "\t" + res.Check + "\t" + res.Value
+		if res.Error != "" {
+			line += "\t" + res.Error
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// Preflight runs the checks declared with the `envcheck` tag on a configuration previously parsed by ParseEnv,
+// and returns a report of their results. Unlike parsing, the checks verify runtime assumptions, such as a listen
+// address being available, and may have side effects like briefly binding a port. The checks are separated by a
+// comma:
+//
+//   - bindable: the address can be listened on, e.g. ":8080" or "localhost:9000". The network is "tcp" unless
+//     given as bindable=udp. A bare port number is accepted.
+//   - readable: the file or directory exists and can be read.
+//   - writable: the file or directory can be written, or created in its parent directory.
+//   - exec: the executable is found with exec.LookPath.
+//   - scheme: the URL has a scheme and a host. With scheme=a|b, the scheme must be one of the given ones.
+//
+// The paths are checked on the filesystem set with SetFS, if any. Slice elements are checked one by one, and
+// empty values are skipped. An error is returned only for an invalid config or envcheck tag.
+func (e *Enviro) Preflight(ctx context.Context, config any) (*Report, error) {
+	report := new(Report)
+	err := walkFields(config, e.prefix, func(bf boundField) error {
+		tag := bf.field.Tag.Get("envcheck")
+		if tag == "" {
+			return nil
+		}
+		for _, check := range strings.Split(tag, ",") {
+			check = strings.TrimSpace(check)
+			name, arg, _ := strings.Cut(check, "=")
+			fn, ok := preflightChecks[name]
+			if !ok {
+				return fmt.Errorf("invalid envcheck tag for field %s: unsupported check %q", bf.field.Name, check)
+			}
+
+			values := checkValues(bf.value)
+			if len(values) == 0 {
+				report.Results = append(report.Results, CheckResult{Key: bf.key, Field: bf.field.Name, Check: check, Status: CheckSkipped})
+				continue
+			}
+			for _, value := range values {
+				res := CheckResult{Key: bf.key, Field: bf.field.Name, Check: check, Value: redactURL(value), Status: CheckPassed}
+				if err := fn(ctx, e, value, arg); err != nil {
+					res.Status = CheckFailed
+					res.Error = err.Error()
+				}
+				report.Results = append(report.Results, res)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type preflightCheck func(ctx context.Context, e *Enviro, value, arg string) error
+
+var preflightChecks = map[string]preflightCheck{
+	"bindable": checkBindable,
+	"readable": func(_ context.Context, e *Enviro, value, _ string) error {
+		return e.checkPath(value, pathOptions{readable: true})
+	},
+	"writable": func(_ context.Context, e *Enviro, value, _ string) error {
+		return e.checkPath(value, pathOptions{writable: true})
+	},
+	"exec": func(_ context.Context, _ *Enviro, value, _ string) error {
+		_, err := exec.LookPath(value)
+		return err
+	},
+	"scheme": checkScheme,
+}
+
+func checkBindable(ctx context.Context, _ *Enviro, value, network string) error {
+	if _, err := strconv.ParseUint(value, 10, 16); err == nil {
+		value = ":" + value
+	}
+
+	var lc net.ListenConfig
+	switch network {
+	case "", "tcp", "tcp4", "tcp6":
+		if network == "" {
+			network = "tcp"
+		}
+		l, err := lc.Listen(ctx, network, value)
+		if err != nil {
+			return err
+		}
+		return l.Close()
+	case "udp", "udp4", "udp6":
+		c, err := lc.ListenPacket(ctx, network, value)
+		if err != nil {
+			return err
+		}
+		return c.Close()
+	default:
+		return fmt.Errorf("unsupported network %q", network)
+	}
+}
+
+func checkScheme(_ context.Context, _ *Enviro, value, schemes string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return errors.New("invalid URL")
+	}
+	if u.Scheme == "" {
+		return errors.New("missing URL scheme")
+	}
+	if schemes != "" && !containsFold(strings.Split(schemes, "|"), u.Scheme) {
+		return fmt.Errorf("unexpected URL scheme %q, expected one of %s", u.Scheme, strings.ReplaceAll(schemes, "|", ", "))
+	}
+	if u.Host == "" && u.Opaque == "" {
+		return errors.New("missing URL host")
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkValues returns the string representation of a field value to check, or of each of its elements for a
+// slice or an array. Zero values are omitted.
+func checkValues(v reflect.Value) []string {
+	v = unwrapDynamic(v)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			return nonEmpty(s.String())
+		}
+		v = v.Elem()
+	}
+
+	if v.CanInterface() {
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			if v.IsZero() {
+				return nil
+			}
+			return nonEmpty(s.String())
+		}
+		if v.CanAddr() {
+			if s, ok := v.Addr().Interface().(fmt.Stringer); ok {
+				if v.IsZero() {
+					return nil
+				}
+				return nonEmpty(s.String())
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, checkValues(v.Index(i))...)
+		}
+		return values
+	case reflect.String:
+		return nonEmpty(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() == 0 {
+			return nil
+		}
+		return []string{strconv.FormatInt(v.Int(), 10)}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() == 0 {
+			return nil
+		}
+		return []string{strconv.FormatUint(v.Uint(), 10)}
+	}
+	return nil
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+// redactURL hides the password of a value that parses as a URL with user information.
+func redactURL(value string) string {
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return value
+}
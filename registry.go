@@ -0,0 +1,130 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// valueParser parses a value and sets the field accordingly. The field type is always the type the parser
+// was registered for.
+type valueParser func(field reflect.Value, value string) error
+
+// valueFormatter returns the string representation of a value of the type it was registered for.
+type valueFormatter func(field reflect.Value) (string, error)
+
+// errNoFormatter is reported when formatting a value of a type with a registered parser but no formatter.
+var errNoFormatter = errors.New("no formatter registered")
+
+var (
+	globalMu         sync.RWMutex
+	globalParsers    = make(map[reflect.Type]valueParser)
+	globalFormatters = make(map[reflect.Type]valueFormatter)
+)
+
+// RegisterParser registers fn as the parser for values of type T, for every Enviro instance. This is the way to
+// teach Enviro about third-party types that can't implement ParseField, such as decimal types or identifiers
+// from another package. Registered parsers take precedence over any other parsing logic, including ParseField,
+// and apply to fields of type T or *T, slice and array elements, and map keys and values. Registering a parser
+// for a type that already has one replaces it. It is safe to call RegisterParser concurrently, but it is
+// typically done once at program startup.
+func RegisterParser[T any](fn func(value string) (T, error)) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalParsers[reflect.TypeOf((*T)(nil)).Elem()] = newValueParser(fn)
+}
+
+// RegisterInstanceParser is like RegisterParser but registers fn for the given Enviro instance only. Instance
+// parsers take precedence over the ones registered with RegisterParser. RegisterInstanceParser must not be
+// called concurrently with other methods of e.
+func RegisterInstanceParser[T any](e *Enviro, fn func(value string) (T, error)) {
+	if e.parsers == nil {
+		e.parsers = make(map[reflect.Type]valueParser)
+	}
+	e.parsers[reflect.TypeOf((*T)(nil)).Elem()] = newValueParser(fn)
+}
+
+// RegisterFormatter registers fn as the formatter for values of type T, for every Enviro instance. It is the
+// counterpart of RegisterParser, used by Marshal and the functions built on it, such as Diff and Describe, to
+// format a value so that the parser registered for T parses it back. A type with a registered parser but no
+// formatter is formatted with its String method if it implements fmt.Stringer, and can't be formatted otherwise.
+// Registering a formatter for a type that already has one replaces it.
+func RegisterFormatter[T any](fn func(value T) (string, error)) {
+	globalMu.Lock()
+	defer globalMu.Unlock()
+	globalFormatters[reflect.TypeOf((*T)(nil)).Elem()] = newValueFormatter(fn)
+}
+
+// RegisterInstanceFormatter is like RegisterFormatter but registers fn for the given Enviro instance only.
+// Instance formatters take precedence over the ones registered with RegisterFormatter. RegisterInstanceFormatter
+// must not be called concurrently with other methods of e.
+func RegisterInstanceFormatter[T any](e *Enviro, fn func(value T) (string, error)) {
+	if e.formatters == nil {
+		e.formatters = make(map[reflect.Type]valueFormatter)
+	}
+	e.formatters[reflect.TypeOf((*T)(nil)).Elem()] = newValueFormatter(fn)
+}
+
+func newValueParser[T any](fn func(value string) (T, error)) valueParser {
+	return func(field reflect.Value, value string) error {
+		v, err := fn(value)
+		if err != nil {
+			return err
+		}
+		// Going through a pointer preserves interface types, which reflect.ValueOf(v) would lose.
+		field.Set(reflect.ValueOf(&v).Elem())
+		return nil
+	}
+}
+
+// lookupParser returns the parser registered for typ, looking at the instance parsers first.
+func (e *Enviro) lookupParser(typ reflect.Type) (valueParser, bool) {
+	if parser, ok := e.parsers[typ]; ok {
+		return parser, true
+	}
+
+	globalMu.RLock()
+	defer globalMu.RUnlock()
+	parser, ok := globalParsers[typ]
+	return parser, ok
+}
+
+func newValueFormatter[T any](fn func(value T) (string, error)) valueFormatter {
+	return func(field reflect.Value) (string, error) {
+		return fn(field.Interface().(T))
+	}
+}
+
+// lookupFormatter returns the formatter registered for typ, looking at the instance formatters first. A type
+// with a registered parser but no formatter falls back to fmt.Stringer, and reports an error otherwise, rather
+// than being formatted by the rules of its kind that the parser may not understand.
+func (e *Enviro) lookupFormatter(typ reflect.Type) (valueFormatter, bool) {
+	if formatter, ok := e.formatters[typ]; ok {
+		return formatter, true
+	}
+
+	globalMu.RLock()
+	formatter, ok := globalFormatters[typ]
+	globalMu.RUnlock()
+	if ok {
+		return formatter, true
+	}
+
+	if _, ok := e.lookupParser(typ); !ok {
+		return nil, false
+	}
+	return func(field reflect.Value) (string, error) {
+		if field.Type().Implements(stringerType) {
+			return field.Interface().(fmt.Stringer).String(), nil
+		}
+		if field.CanAddr() && field.Addr().Type().Implements(stringerType) {
+			return field.Addr().Interface().(fmt.Stringer).String(), nil
+		}
+		return "", fmt.Errorf("%w for %s", errNoFormatter, field.Type())
+	}, true
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type cents struct {
+	value int64
+}
+
+func parseCents(value string) (cents, error) {
+	units, frac, _ := strings.Cut(value, ".")
+	u, err := strconv.ParseInt(units+frac, 10, 64)
+	if err != nil {
+		return cents{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if len(frac) == 0 {
+		u *= 100
+	}
+	return cents{value: u}, nil
+}
+
+func TestRegisterParser(t *testing.T) {
+	type Config struct {
+		Price  cents            `enviro:"price"`
+		Max    *cents           `enviro:"max"`
+		Prices []cents          `enviro:"prices"`
+		Plans  map[string]cents `enviro:"plans"`
+	}
+
+	restoreRegistry(t)
+	RegisterParser(parseCents)
+
+	os.Setenv("PRICE", "1.50")
+	os.Setenv("MAX", "10")
+	os.Setenv("PRICES", "1.00, 2.50")
+	os.Setenv("PLANS", "free=0, pro=9.99")
+	defer func() {
+		os.Unsetenv("PRICE")
+		os.Unsetenv("MAX")
+		os.Unsetenv("PRICES")
+		os.Unsetenv("PLANS")
+	}()
+
+	expected := Config{
+		Price:  cents{150},
+		Max:    &cents{1000},
+		Prices: []cents{{100}, {250}},
+		Plans:  map[string]cents{"free": {0}, "pro": {999}},
+	}
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+
+	// Instance parsers take precedence over global ones
+	RegisterInstanceParser(e, func(value string) (cents, error) {
+		return cents{value: -1}, nil
+	})
+	config = Config{}
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	if config.Price.value != -1 || config.Max.value != -1 {
+		t.Errorf("Expected instance parser to be used, got %+v", config)
+	}
+
+	os.Setenv("PRICE", "free")
+	config = Config{}
+	if err := New().ParseEnv(&config); err == nil || !strings.Contains(err.Error(), `invalid amount "free"`) {
+		t.Errorf("Expected parser error, got %v", err)
+	}
+}
+
+func TestRegisterParserPrecedence(t *testing.T) {
+	type Config struct {
+		Mode *testMode `enviro:"mode"`
+	}
+
+	restoreRegistry(t)
+	RegisterEnum(map[string]testMode{"dev": modeDev, "prod": modeProd})
+	RegisterParser(func(value string) (testMode, error) {
+		return modeStaging, nil
+	})
+
+	os.Setenv("MODE", "prod")
+	defer os.Unsetenv("MODE")
+
+	var config Config
+	if err := New().ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	if config.Mode == nil || *config.Mode != modeStaging {
+		t.Errorf("Expected the registered parser to take precedence over the enum, got %v", config.Mode)
+	}
+}
+
+type zid struct {
+	n uint64
+}
+
+type label struct {
+	s string
+}
+
+func (l *label) String() string {
+	return "label:" + l.s
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	type Config struct {
+		ID     zid              `enviro:"id"`
+		Max    *cents           `enviro:"max"`
+		Prices map[string]cents `enviro:"prices"`
+		Label  label            `enviro:"label"`
+	}
+
+	restoreRegistry(t)
+	RegisterParser(parseCents)
+
+	e := New()
+	RegisterInstanceParser(e, func(value string) (zid, error) {
+		n, err := strconv.ParseUint(value, 36, 64)
+		return zid{n}, err
+	})
+	RegisterInstanceParser(e, func(value string) (label, error) {
+		return label{strings.TrimPrefix(value, "label:")}, nil
+	})
+
+	max := cents{1050}
+	config := Config{ID: zid{35}, Max: &max, Prices: map[string]cents{"pro": {999}}, Label: label{"a"}}
+
+	// A registered parser without a formatter is only formatted through fmt.Stringer
+	if _, err := e.Marshal(&config); err == nil || !strings.Contains(err.Error(), "no formatter registered for enviro.zid") {
+		t.Errorf("Expected a missing formatter error, got %v", err)
+	}
+
+	RegisterInstanceFormatter(e, func(v zid) (string, error) {
+		return strconv.FormatUint(v.n, 36), nil
+	})
+	RegisterFormatter(func(v cents) (string, error) {
+		return fmt.Sprintf("%d.%02d", v.value/100, v.value%100), nil
+	})
+	env, err := e.Marshal(&config)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %s", err)
+	}
+	expected := []string{"ID=z", "MAX=10.50", "PRICES=pro=9.99", "LABEL=label:a"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, env)
+	}
+
+	vars := make(map[string]string)
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		vars[k] = v
+	}
+	e.SetLookupFunc(func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	})
+	var parsed Config
+	if err := e.ParseEnv(&parsed); err != nil {
+		t.Fatalf("Failed to parse environment variables: %s", err)
+	}
+	if !reflect.DeepEqual(parsed, config) {
+		t.Errorf("Expected %+v, got %+v", config, parsed)
+	}
+}
+
+// restoreRegistry restores the global parsers and enum tables when the test ends, so that the types registered by
+// a test do not leak into the others.
+func restoreRegistry(t *testing.T) {
+	t.Helper()
+	globalMu.Lock()
+	parsers := make(map[reflect.Type]valueParser, len(globalParsers))
+	for typ, parser := range globalParsers {
+		parsers[typ] = parser
+	}
+	formatters := make(map[reflect.Type]valueFormatter, len(globalFormatters))
+	for typ, formatter := range globalFormatters {
+		formatters[typ] = formatter
+	}
+	tables := make(map[reflect.Type]*enumTable, len(enumTables))
+	for typ, table := range enumTables {
+		tables[typ] = table
+	}
+	globalMu.Unlock()
+
+	t.Cleanup(func() {
+		globalMu.Lock()
+		defer globalMu.Unlock()
+		globalParsers = parsers
+		globalFormatters = formatters
+		enumTables = tables
+	})
+}
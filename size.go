@@ -0,0 +1,146 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+)
+
+// ByteSize is a number of bytes that can be written with a unit suffix in environment variables, e.g. "10MB" or
+// "512Mi". Integer fields of any other type accept the same notation with the `envopt:"bytes"` option.
+//
+// SI suffixes (k, kB, M, MB, G, GB, T, TB, P, PB, E, EB) are powers of 1000, and IEC suffixes (Ki, KiB, Mi, MiB,
+// Gi, GiB, Ti, TiB, Pi, PiB, Ei, EiB) are powers of 1024. As in Kubernetes quantities, "K" and "KB" are accepted
+// for kilo, the number may have a decimal fraction ("1.5Gi") and a plain number may use an exponent ("1e9"). The
+// result must be a whole number of bytes.
+type ByteSize uint64
+
+// Common byte sizes.
+const (
+	Byte ByteSize = 1
+	KiB           = 1024 * Byte
+	MiB           = 1024 * KiB
+	GiB           = 1024 * MiB
+	TiB           = 1024 * GiB
+	PiB           = 1024 * TiB
+	EiB           = 1024 * PiB
+	KB   ByteSize = 1000
+	MB            = 1000 * KB
+	GB            = 1000 * MB
+	TB            = 1000 * GB
+	PB            = 1000 * TB
+	EB            = 1000 * PB
+)
+
+// String formats the size with the largest IEC unit that represents it exactly, e.g. "512Mi" or "1536".
+func (b ByteSize) String() string {
+	units := []struct {
+		suffix string
+		size   ByteSize
+	}{{"Ei", EiB}, {"Pi", PiB}, {"Ti", TiB}, {"Gi", GiB}, {"Mi", MiB}, {"Ki", KiB}}
+	for _, u := range units {
+		if b >= u.size && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.suffix
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10)
+}
+
+var byteSizeSuffixes = map[string]*big.Int{
+	"":    big.NewInt(1),
+	"B":   big.NewInt(1),
+	"k":   big.NewInt(int64(KB)),
+	"K":   big.NewInt(int64(KB)),
+	"kB":  big.NewInt(int64(KB)),
+	"KB":  big.NewInt(int64(KB)),
+	"M":   big.NewInt(int64(MB)),
+	"MB":  big.NewInt(int64(MB)),
+	"G":   big.NewInt(int64(GB)),
+	"GB":  big.NewInt(int64(GB)),
+	"T":   big.NewInt(int64(TB)),
+	"TB":  big.NewInt(int64(TB)),
+	"P":   big.NewInt(int64(PB)),
+	"PB":  big.NewInt(int64(PB)),
+	"E":   big.NewInt(int64(EB)),
+	"EB":  big.NewInt(int64(EB)),
+	"Ki":  big.NewInt(int64(KiB)),
+	"KiB": big.NewInt(int64(KiB)),
+	"Mi":  big.NewInt(int64(MiB)),
+	"MiB": big.NewInt(int64(MiB)),
+	"Gi":  big.NewInt(int64(GiB)),
+	"GiB": big.NewInt(int64(GiB)),
+	"Ti":  big.NewInt(int64(TiB)),
+	"TiB": big.NewInt(int64(TiB)),
+	"Pi":  big.NewInt(int64(PiB)),
+	"PiB": big.NewInt(int64(PiB)),
+	"Ei":  big.NewInt(int64(EiB)),
+	"EiB": big.NewInt(int64(EiB)),
+}
+
+var byteSizeRegexp = regexp.MustCompile(`^([+-]?[0-9]+(?:\.[0-9]+)?)(?:[eE]([+-]?[0-9]+))?\s*([A-Za-z]*)$`)
+
+// parseByteSize parses a size with an optional unit suffix and returns the number of bytes.
+func parseByteSize(value string) (*big.Int, error) {
+	m := byteSizeRegexp.FindStringSubmatch(value)
+	if m == nil {
+		return nil, fmt.Errorf("invalid byte size %q", value)
+	}
+
+	multiplier, ok := byteSizeSuffixes[m[3]]
+	if !ok {
+		return nil, fmt.Errorf("invalid byte size %q: unknown unit %q", value, m[3])
+	}
+	if m[2] != "" && m[3] != "" {
+		return nil, fmt.Errorf("invalid byte size %q: exponent and unit are mutually exclusive", value)
+	}
+
+	r, _ := new(big.Rat).SetString(m[1])
+	if m[2] != "" {
+		exp, err := strconv.Atoi(m[2])
+		if err != nil || exp > 100 || exp < -100 {
+			return nil, fmt.Errorf("invalid byte size %q: exponent out of range", value)
+		}
+		pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+		if exp < 0 {
+			pow.SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-exp)), nil))
+		}
+		r.Mul(r, pow)
+	}
+	r.Mul(r, new(big.Rat).SetInt(multiplier))
+
+	if !r.IsInt() {
+		return nil, fmt.Errorf("invalid byte size %q: not a whole number of bytes", value)
+	}
+	return r.Num(), nil
+}
+
+func (e *Enviro) setByteSizeField(field reflect.Value, value string) error {
+	n, err := parseByteSize(value)
+	if err != nil {
+		return err
+	}
+
+	bits := field.Type().Bits()
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !n.IsInt64() || field.OverflowInt(n.Int64()) {
+			return fmt.Errorf("byte size %q overflows %d-bit integer", value, bits)
+		}
+		field.SetInt(n.Int64())
+	default:
+		if n.Sign() < 0 {
+			return fmt.Errorf("invalid byte size %q: negative size", value)
+		}
+		if !n.IsUint64() || field.OverflowUint(n.Uint64()) {
+			return fmt.Errorf("byte size %q overflows %d-bit unsigned integer", value, bits)
+		}
+		field.SetUint(n.Uint64())
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := []struct {
+		value string
+		want  int64
+		err   string
+	}{
+		{value: "1024", want: 1024},
+		{value: "10MB", want: 10_000_000},
+		{value: "10 MB", want: 10_000_000},
+		{value: "512Mi", want: 512 << 20},
+		{value: "1.5GiB", want: 3 << 29},
+		{value: "2k", want: 2000},
+		{value: "1e3", want: 1000},
+		{value: "1E", want: 1e18},
+		{value: "-1Ki", want: -1024},
+		{value: "10mb", err: `unknown unit "mb"`},
+		{value: "1.5", err: "not a whole number of bytes"},
+		{value: "1e3k", err: "invalid byte size"},
+		{value: "MB", err: "invalid byte size"},
+	}
+
+	for _, tc := range cases {
+		n, err := parseByteSize(tc.value)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("parseByteSize(%q): expected error containing %q, got %v", tc.value, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseByteSize(%q): unexpected error: %s", tc.value, err)
+			continue
+		}
+		if n.Int64() != tc.want {
+			t.Errorf("parseByteSize(%q): expected %d, got %s", tc.value, tc.want, n)
+		}
+	}
+}
+
+func TestParseEnvByteSize(t *testing.T) {
+	type Config struct {
+		MaxBody  ByteSize          `enviro:"max_body"`
+		Cache    *int64            `enviro:"cache" envopt:"bytes"`
+		Buffers  []uint32          `enviro:"buffers" envopt:"bytes"`
+		Limits   map[string]uint64 `enviro:"limits" envopt:"bytes"`
+		Overflow uint16            `enviro:"overflow" envopt:"bytes"`
+	}
+
+	os.Setenv("MAX_BODY", "10MB")
+	os.Setenv("CACHE", "512Mi")
+	os.Setenv("BUFFERS", "4Ki,64KiB")
+	os.Setenv("LIMITS", "upload=1G")
+	defer func() {
+		os.Unsetenv("MAX_BODY")
+		os.Unsetenv("CACHE")
+		os.Unsetenv("BUFFERS")
+		os.Unsetenv("LIMITS")
+		os.Unsetenv("OVERFLOW")
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse byte size environment variables: %s", err)
+	}
+
+	cache := int64(512 << 20)
+	expected := Config{
+		MaxBody: 10 * MB,
+		Cache:   &cache,
+		Buffers: []uint32{4 << 10, 64 << 10},
+		Limits:  map[string]uint64{"upload": 1e9},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, config)
+	}
+	if s := config.MaxBody.String(); s != "10000000" {
+		t.Errorf("Expected 10000000, got %s", s)
+	}
+	if s := (512 * MiB).String(); s != "512Mi" {
+		t.Errorf("Expected 512Mi, got %s", s)
+	}
+
+	os.Setenv("OVERFLOW", "64Ki")
+	if err := e.ParseEnv(&config); err == nil || !strings.Contains(err.Error(), "overflows 16-bit unsigned integer") {
+		t.Errorf("Expected overflow error, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"text/template"
+)
+
+// setRegexp compiles a regular expression, using the POSIX ERE syntax and leftmost-longest matching with
+// `envopt:"regexp:posix"`.
+func setRegexp(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	compile := regexp.Compile
+	if syntax, ok := opts.Lookup("regexp"); ok {
+		if syntax != "posix" {
+			return fmt.Errorf("unsupported regexp option %q", syntax)
+		}
+		compile = regexp.CompilePOSIX
+	}
+
+	re, err := compile(value)
+	if err != nil {
+		return fmt.Errorf("invalid regular expression: %w", err)
+	}
+	field.Set(reflect.ValueOf(re))
+	return nil
+}
+
+// setTemplate parses a text template named after the environment variable, with the functions set by
+// SetTemplateFuncs.
+func setTemplate(e *Enviro, field reflect.Value, value string, opts Options) error {
+	tmpl, err := template.New(opts.key).Funcs(e.templateFuncs).Parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	field.Set(reflect.ValueOf(tmpl))
+	return nil
+}
+
+// formatTemplate returns the source of a template, as reconstructed from its parse tree.
+func formatTemplate(_ *Enviro, field reflect.Value, _ Options) (string, error) {
+	tmpl := field.Interface().(*template.Template)
+	if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+		return "", nil
+	}
+	return tmpl.Tree.Root.String(), nil
+}
@@ -13,8 +13,8 @@ type timeFormatType int
 
 const (
 	timeFormatNoTimezone timeFormatType = iota
-	timeFormatNumericTimezone
 	timeFormatNamedTimezone
+	timeFormatNumericTimezone
 	timeFormatNumericAndNamedTimezone
 	timeFormatTimeOnly
 )
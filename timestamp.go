@@ -0,0 +1,169 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Special time formats that may be used in place of a layout in the `time` option or with SetTimeLayouts.
+const (
+	TimeUnix      = "unix"
+	TimeUnixMilli = "unixmilli"
+	TimeUnixMicro = "unixmicro"
+	TimeUnixNano  = "unixnano"
+	TimeRelative  = "relative"
+)
+
+var relativeTimeRegexp = regexp.MustCompile(`(?i)^(now|today|yesterday|tomorrow)\s*(?:([+-])\s*(\S+))?$`)
+
+func (e *Enviro) location() *time.Location {
+	if e.loc != nil {
+		return e.loc
+	}
+	return time.UTC
+}
+
+func (e *Enviro) timeNow() time.Time {
+	if e.now != nil {
+		return e.now()
+	}
+	return time.Now()
+}
+
+// parseTimeWith tries each layout in order and returns the first successful result. If there is a single
+// layout, its error is returned as is.
+func (e *Enviro) parseTimeWith(value string, layouts []string, loc *time.Location) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = e.parseTime(value, layout, loc); err == nil {
+			return t, nil
+		}
+	}
+	if len(layouts) == 1 {
+		return time.Time{}, err
+	}
+	return time.Time{}, fmt.Errorf("unable to parse date %q with any of the layouts %q", value, layouts)
+}
+
+func (e *Enviro) parseTime(value, layout string, loc *time.Location) (time.Time, error) {
+	switch layout {
+	case TimeUnix:
+		return parseUnixTime(value, time.Second, loc)
+	case TimeUnixMilli:
+		return parseUnixTime(value, time.Millisecond, loc)
+	case TimeUnixMicro:
+		return parseUnixTime(value, time.Microsecond, loc)
+	case TimeUnixNano:
+		return parseUnixTime(value, time.Nanosecond, loc)
+	case TimeRelative:
+		return e.parseRelativeTime(value, loc)
+	}
+	return time.ParseInLocation(layout, value, loc)
+}
+
+// parseUnixTime parses a possibly fractional number of units elapsed since the Unix epoch.
+func parseUnixTime(value string, unit time.Duration, loc *time.Location) (time.Time, error) {
+	if !bareNumberRegexp.MatchString(value) {
+		return time.Time{}, fmt.Errorf("invalid Unix timestamp %q", value)
+	}
+	r, _ := new(big.Rat).SetString(value)
+	r.Mul(r, new(big.Rat).SetInt64(int64(unit)))
+	ns := new(big.Int).Quo(r.Num(), r.Denom())
+
+	sec, nsec := new(big.Int).QuoRem(ns, big.NewInt(int64(time.Second)), new(big.Int))
+	if !sec.IsInt64() {
+		return time.Time{}, fmt.Errorf("invalid Unix timestamp %q: out of range", value)
+	}
+	return time.Unix(sec.Int64(), nsec.Int64()).In(loc), nil
+}
+
+// parseRelativeTime parses a time relative to the current time, such as "now", "today", "now-24h" or
+// "today+8h". The offset accepts day (d) and week (w) units, and "today", "yesterday" and "tomorrow" refer to
+// midnight in the given location.
+func (e *Enviro) parseRelativeTime(value string, loc *time.Location) (time.Time, error) {
+	m := relativeTimeRegexp.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid relative time %q", value)
+	}
+
+	now := e.timeNow().In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var t time.Time
+	switch strings.ToLower(m[1]) {
+	case "now":
+		t = now
+	case "today":
+		t = midnight
+	case "yesterday":
+		t = midnight.AddDate(0, 0, -1)
+	case "tomorrow":
+		t = midnight.AddDate(0, 0, 1)
+	}
+
+	if m[2] != "" {
+		d, err := parseExtendedDuration(m[3])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time %q: %w", value, err)
+		}
+		if m[2] == "-" {
+			d = -d
+		}
+		t = t.Add(d)
+	}
+	return t, nil
+}
+
+// formatTime formats a time with the first layout of the field, or of the instance, that can represent it. The
+// relative format is skipped, and the default is RFC 3339 with nanoseconds, which every default layout accepts.
+func formatTime(e *Enviro, field reflect.Value, opts Options) (string, error) {
+	t := field.Interface().(time.Time)
+	layouts, location := parseTimeFormatTag(opts)
+	if len(layouts) == 0 {
+		layouts = e.timeLayouts
+	}
+	loc := e.location()
+	if location != "" {
+		var err error
+		if loc, err = time.LoadLocation(location); err != nil {
+			return "", err
+		}
+	}
+
+	for _, layout := range layouts {
+		switch layout {
+		case TimeRelative:
+			continue
+		case TimeUnix:
+			return formatUnixTime(t, time.Second), nil
+		case TimeUnixMilli:
+			return formatUnixTime(t, time.Millisecond), nil
+		case TimeUnixMicro:
+			return formatUnixTime(t, time.Microsecond), nil
+		case TimeUnixNano:
+			return formatUnixTime(t, time.Nanosecond), nil
+		}
+		return t.In(loc).Format(layout), nil
+	}
+	return t.Format(time.RFC3339Nano), nil
+}
+
+// formatUnixTime is the inverse of parseUnixTime.
+func formatUnixTime(t time.Time, unit time.Duration) string {
+	ns := new(big.Int).Mul(big.NewInt(t.Unix()), big.NewInt(int64(time.Second)))
+	ns.Add(ns, big.NewInt(int64(t.Nanosecond())))
+	r := new(big.Rat).SetFrac(ns, big.NewInt(int64(unit)))
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	return strings.TrimRight(r.FloatString(9), "0")
+}
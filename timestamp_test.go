@@ -0,0 +1,101 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseEnvTimeFormats(t *testing.T) {
+	type Config struct {
+		Unix      time.Time   `enviro:"unix" envopt:"time:unix"`
+		UnixMilli *time.Time  `enviro:"unix_milli" envopt:"time:unixmilli"`
+		Since     time.Time   `enviro:"since" envopt:"time:relative"`
+		Until     time.Time   `enviro:"until" envopt:"time:relative|2006-01-02,America/New_York"`
+		Stamps    []time.Time `enviro:"stamps" envopt:"time:unix|relative"`
+		Offset    time.Time   `enviro:"offset"`
+		Local     time.Time   `enviro:"local"`
+		Day       time.Time   `enviro:"day" envopt:"time:Mon, 02 Jan 2006"`
+		Month     time.Time   `enviro:"month" envopt:"time:Jan 2,2006"`
+	}
+
+	os.Setenv("UNIX", "1700000000")
+	os.Setenv("UNIX_MILLI", "1700000000123.5")
+	os.Setenv("SINCE", "now - 24h")
+	os.Setenv("UNTIL", "2024-06-01")
+	os.Setenv("STAMPS", "0, yesterday")
+	os.Setenv("OFFSET", "2024-01-01T10:00:00+02:00")
+	os.Setenv("LOCAL", "2024-01-01 10:00:00")
+	os.Setenv("DAY", "Fri, 01 Mar 2024")
+	os.Setenv("MONTH", "Mar 1,2024")
+	defer func() {
+		os.Unsetenv("UNIX")
+		os.Unsetenv("UNIX_MILLI")
+		os.Unsetenv("SINCE")
+		os.Unsetenv("UNTIL")
+		os.Unsetenv("STAMPS")
+		os.Unsetenv("OFFSET")
+		os.Unsetenv("LOCAL")
+		os.Unsetenv("DAY")
+		os.Unsetenv("MONTH")
+	}()
+
+	paris, _ := time.LoadLocation("Europe/Paris")
+	newYork, _ := time.LoadLocation("America/New_York")
+	now := time.Date(2024, 3, 15, 12, 30, 0, 0, time.UTC)
+
+	var config Config
+	e := New()
+	e.now = func() time.Time { return now }
+	e.SetTimeLocation(paris)
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse time environment variables: %s", err)
+	}
+
+	checks := []struct {
+		name string
+		got  time.Time
+		want time.Time
+	}{
+		{"unix", config.Unix, time.Unix(1700000000, 0)},
+		{"unixmilli", *config.UnixMilli, time.Unix(1700000000, 123500000)},
+		{"relative", config.Since, now.Add(-24 * time.Hour)},
+		{"layout list", config.Until, time.Date(2024, 6, 1, 0, 0, 0, 0, newYork)},
+		{"slice unix", config.Stamps[0], time.Unix(0, 0)},
+		{"slice relative", config.Stamps[1], time.Date(2024, 3, 14, 0, 0, 0, 0, paris)},
+		{"numeric offset", config.Offset, time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)},
+		{"default location", config.Local, time.Date(2024, 1, 1, 10, 0, 0, 0, paris)},
+		{"layout with a comma", config.Day, time.Date(2024, 3, 1, 0, 0, 0, 0, paris)},
+		{"layout ending with a comma", config.Month, time.Date(2024, 3, 1, 0, 0, 0, 0, paris)},
+	}
+	for _, c := range checks {
+		if !c.got.Equal(c.want) {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, c.got)
+		}
+	}
+	if config.Unix.Location() != paris {
+		t.Errorf("Expected Unix time in %s, got %s", paris, config.Unix.Location())
+	}
+
+	e.SetTimeLayouts("02/01/2006", TimeUnix)
+	os.Setenv("LOCAL", "25/12/2024")
+	os.Setenv("OFFSET", "1700000000")
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse time environment variables: %s", err)
+	}
+	if want := time.Date(2024, 12, 25, 0, 0, 0, 0, paris); !config.Local.Equal(want) {
+		t.Errorf("Expected %s, got %s", want, config.Local)
+	}
+	if want := time.Unix(1700000000, 0); !config.Offset.Equal(want) {
+		t.Errorf("Expected %s, got %s", want, config.Offset)
+	}
+
+	os.Setenv("SINCE", "later")
+	if err := e.ParseEnv(&config); err == nil {
+		t.Errorf("Expected an error for an invalid relative time")
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// TLSConfig is a ready-made block of TLS settings, meant to be nested in a configuration struct:
+//
+//	type Config struct {
+//		TLS enviro.TLSConfig `enviro:"nested:tls"`
+//	}
+//
+// Certificates, keys and certificate authorities are given either as inline PEM data or as a path to a PEM
+// file. The minimum version is one of 1.0, 1.1, 1.2 (the default) or 1.3, and the client authentication policy
+// one of none (the default), request, require, verify_if_given or require_and_verify.
+type TLSConfig struct {
+	// Cert is the certificate chain, leaf first.
+	Cert []*x509.Certificate `enviro:"cert" envopt:"pem:auto"`
+	// Key is the private key of the leaf certificate.
+	Key crypto.PrivateKey `enviro:"key" envopt:"pem:auto"`
+	// CA is the set of certificate authorities used to verify servers. The system pool is used if empty.
+	CA *x509.CertPool `enviro:"ca" envopt:"pem:auto"`
+	// ClientCA is the set of certificate authorities used to verify clients.
+	ClientCA *x509.CertPool `enviro:"client_ca" envopt:"pem:auto"`
+	// ServerName is used to verify the hostname of servers.
+	ServerName string `enviro:"server_name"`
+	// MinVersion is the minimum TLS version.
+	MinVersion uint16 `enviro:"min_version" envopt:"enum:1.0=0x0301,1.1=0x0302,1.2=0x0303,1.3=0x0304" envdefault:"1.2"`
+	// ClientAuth is the policy for TLS client authentication.
+	ClientAuth tls.ClientAuthType `enviro:"client_auth" envopt:"enum:none,request,require,verify_if_given,require_and_verify" envdefault:"none"`
+	// InsecureSkipVerify disables the verification of server certificates.
+	InsecureSkipVerify bool `enviro:"insecure_skip_verify"`
+}
+
+// Config returns a *tls.Config built from the settings. It returns an error if only one of the certificate and
+// the key is set, or if they don't match.
+func (c *TLSConfig) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		RootCAs:            c.CA,
+		ClientCAs:          c.ClientCA,
+		ServerName:         c.ServerName,
+		MinVersion:         c.MinVersion,
+		ClientAuth:         c.ClientAuth,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if len(c.Cert) > 0 || c.Key != nil {
+		cert, err := newTLSCertificate(c.Cert, c.Key)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func newTLSCertificate(chain []*x509.Certificate, key crypto.PrivateKey) (tls.Certificate, error) {
+	if len(chain) == 0 {
+		return tls.Certificate{}, errors.New("tls: private key without certificate")
+	}
+	if key == nil {
+		return tls.Certificate{}, errors.New("tls: certificate without private key")
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return tls.Certificate{}, fmt.Errorf("tls: unsupported private key type %T", key)
+	}
+	pub, ok := chain[0].PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(signer.Public()) {
+		return tls.Certificate{}, errors.New("tls: private key does not match certificate public key")
+	}
+
+	cert := tls.Certificate{PrivateKey: key, Leaf: chain[0]}
+	for _, c := range chain {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	return cert, nil
+}
+
+func setCertificate(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	certs, err := loadCertificates(value, opts)
+	if err != nil {
+		return err
+	}
+	// The first certificate is the leaf of a chain
+	field.Set(reflect.ValueOf(certs[0]))
+	return nil
+}
+
+func setCertificates(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	certs, err := loadCertificates(value, opts)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(certs))
+	return nil
+}
+
+func setCertPool(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	certs, err := loadCertificates(value, opts)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	field.Set(reflect.ValueOf(pool))
+	return nil
+}
+
+func setPrivateKey(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	data, err := loadPEM(value, opts)
+	if err != nil {
+		return err
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return err
+	}
+	if !reflect.TypeOf(key).AssignableTo(field.Type()) {
+		return fmt.Errorf("private key of type %T is not a %s", key, field.Type().String())
+	}
+	field.Set(reflect.ValueOf(key))
+	return nil
+}
+
+// setTLSCertificate parses a certificate chain and its private key. Inline, both are expected in the same
+// value. With `envopt:"pem:file"`, the value is either the path to a file holding both, or the path to the
+// certificate file and the path to the key file separated by a comma.
+func setTLSCertificate(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	var data []byte
+	if certFile, keyFile, found := strings.Cut(value, ","); found && pemMode(opts) == "file" {
+		cert, err := readPEMFile(strings.TrimSpace(certFile))
+		if err != nil {
+			return err
+		}
+		key, err := readPEMFile(strings.TrimSpace(keyFile))
+		if err != nil {
+			return err
+		}
+		data = append(append(cert, '\n'), key...)
+	} else {
+		var err error
+		if data, err = loadPEM(value, opts); err != nil {
+			return err
+		}
+	}
+
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return err
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return err
+	}
+	cert, err := newTLSCertificate(certs, key)
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(cert))
+	return nil
+}
+
+// pemMode returns the value of the `pem` option: "inline" (the default) for PEM data, "file" for a path to a PEM
+// file, or "auto" to accept either.
+func pemMode(opts Options) string {
+	if mode, ok := opts.Lookup("pem"); ok {
+		return mode
+	}
+	return "inline"
+}
+
+// loadPEM returns the PEM data given inline or read from a file, according to the `pem` option. Inline data may
+// have its new lines escaped as "\n", which is common when PEM data goes through an environment file.
+func loadPEM(value string, opts Options) ([]byte, error) {
+	isPEM := strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN")
+	switch mode := pemMode(opts); mode {
+	case "inline":
+		if !isPEM {
+			return nil, errors.New("expected PEM data")
+		}
+	case "file":
+		return readPEMFile(value)
+	case "auto":
+		if !isPEM {
+			return readPEMFile(value)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported pem option %q", mode)
+	}
+
+	if !strings.Contains(value, "\n") {
+		value = strings.ReplaceAll(value, `\n`, "\n")
+	}
+	return []byte(value), nil
+}
+
+func readPEMFile(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PEM file: %w", err)
+	}
+	return data, nil
+}
+
+func loadCertificates(value string, opts Options) ([]*x509.Certificate, error) {
+	data, err := loadPEM(value, opts)
+	if err != nil {
+		return nil, err
+	}
+	return parseCertificates(data)
+}
+
+// parseCertificates parses every CERTIFICATE block of the PEM data, ignoring other blocks.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found in PEM data")
+	}
+	return certs, nil
+}
+
+// parsePrivateKey parses the first private key block of the PEM data, in PKCS #1, PKCS #8 or SEC 1 (EC) form.
+// Encrypted keys are not supported.
+func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no private key found in PEM data")
+		}
+
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid RSA private key: %w", err)
+			}
+			return key, nil
+		case "EC PRIVATE KEY":
+			key, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid EC private key: %w", err)
+			}
+			return key, nil
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid PKCS #8 private key: %w", err)
+			}
+			switch key.(type) {
+			case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+				return key, nil
+			}
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, errors.New("encrypted private keys are not supported")
+		}
+	}
+}
+
+// formatCertificate encodes a certificate as PEM data. PEM files given by their path can't be recovered, so
+// fields with the `pem:file` option can't be formatted.
+func formatCertificate(_ *Enviro, field reflect.Value, opts Options) (string, error) {
+	if pemMode(opts) == "file" {
+		return "", errors.New("cannot format a PEM file path")
+	}
+	return string(encodeCertificates(field.Interface().(*x509.Certificate))), nil
+}
+
+func formatCertificates(_ *Enviro, field reflect.Value, opts Options) (string, error) {
+	if pemMode(opts) == "file" {
+		return "", errors.New("cannot format a PEM file path")
+	}
+	return string(encodeCertificates(field.Interface().([]*x509.Certificate)...)), nil
+}
+
+func formatCertPool(_ *Enviro, _ reflect.Value, _ Options) (string, error) {
+	return "", errors.New("cannot format a certificate pool")
+}
+
+func formatPrivateKey(_ *Enviro, field reflect.Value, opts Options) (string, error) {
+	if pemMode(opts) == "file" {
+		return "", errors.New("cannot format a PEM file path")
+	}
+	data, err := encodePrivateKey(field.Interface())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func formatTLSCertificate(_ *Enviro, field reflect.Value, opts Options) (string, error) {
+	if pemMode(opts) == "file" {
+		return "", errors.New("cannot format a PEM file path")
+	}
+	cert := field.Interface().(tls.Certificate)
+	if len(cert.Certificate) == 0 && cert.PrivateKey == nil {
+		// A certificate left unset by ParseEnv has nothing to format
+		return "", errUnset
+	}
+	var data []byte
+	for _, der := range cert.Certificate {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	key, err := encodePrivateKey(cert.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	return string(append(data, key...)), nil
+}
+
+func encodeCertificates(certs ...*x509.Certificate) []byte {
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return data
+}
+
+// encodePrivateKey encodes a private key as a PKCS #8 PEM block.
+func encodePrivateKey(key crypto.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
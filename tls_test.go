@@ -0,0 +1,194 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "enviro.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestParseEnvCrypto(t *testing.T) {
+	type Config struct {
+		Cert    *x509.Certificate   `enviro:"cert"`
+		Chain   []*x509.Certificate `enviro:"chain" envopt:"pem:file"`
+		Pool    *x509.CertPool      `enviro:"pool" envopt:"pem:auto"`
+		Key     crypto.PrivateKey   `enviro:"key"`
+		Signer  crypto.Signer       `enviro:"signer" envopt:"pem:file"`
+		Pair    tls.Certificate     `enviro:"pair" envopt:"pem:file"`
+		Ed25519 crypto.Signer       `enviro:"ed25519"`
+	}
+
+	certPEM, keyPEM := newTestCertificate(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: edDER})
+
+	env := map[string]string{
+		"CERT":    strings.ReplaceAll(string(certPEM), "\n", `\n`),
+		"CHAIN":   certFile,
+		"POOL":    string(certPEM),
+		"KEY":     string(keyPEM),
+		"SIGNER":  keyFile,
+		"PAIR":    certFile + "," + keyFile,
+		"ED25519": string(edPEM),
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	var config Config
+	e := New()
+	if err := e.ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse crypto environment variables: %s", err)
+	}
+
+	if config.Cert.Subject.CommonName != "enviro.test" {
+		t.Errorf("Expected enviro.test certificate, got %s", config.Cert.Subject.CommonName)
+	}
+	if len(config.Chain) != 1 || !config.Chain[0].Equal(config.Cert) {
+		t.Errorf("Expected a chain of one certificate, got %d", len(config.Chain))
+	}
+	if _, err := config.Cert.Verify(x509.VerifyOptions{Roots: config.Pool}); err != nil {
+		t.Errorf("Expected certificate to verify against the pool: %s", err)
+	}
+	if _, ok := config.Key.(*ecdsa.PrivateKey); !ok {
+		t.Errorf("Expected an ECDSA key, got %T", config.Key)
+	}
+	if !config.Signer.Public().(*ecdsa.PublicKey).Equal(config.Cert.PublicKey) {
+		t.Errorf("Expected the signer to match the certificate")
+	}
+	if config.Pair.Leaf == nil || len(config.Pair.Certificate) != 1 {
+		t.Errorf("Expected a TLS certificate with a leaf")
+	}
+	if _, ok := config.Ed25519.(ed25519.PrivateKey); !ok {
+		t.Errorf("Expected an Ed25519 key, got %T", config.Ed25519)
+	}
+
+	os.Setenv("CERT", certFile)
+	if err := e.ParseEnv(&config); err == nil || !strings.Contains(err.Error(), "expected PEM data") {
+		t.Errorf("Expected an error for a path without the pem:file option, got %v", err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	type Config struct {
+		TLS TLSConfig `enviro:"nested:tls"`
+	}
+
+	certPEM, keyPEM := newTestCertificate(t)
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("TLS_CERT", string(certPEM))
+	os.Setenv("TLS_KEY", keyFile)
+	os.Setenv("TLS_CLIENT_CA", string(certPEM))
+	os.Setenv("TLS_MIN_VERSION", "1.3")
+	os.Setenv("TLS_CLIENT_AUTH", "require_and_verify")
+	defer func() {
+		os.Unsetenv("TLS_CERT")
+		os.Unsetenv("TLS_KEY")
+		os.Unsetenv("TLS_CLIENT_CA")
+		os.Unsetenv("TLS_MIN_VERSION")
+		os.Unsetenv("TLS_CLIENT_AUTH")
+	}()
+
+	var config Config
+	if err := New().ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse TLS environment variables: %s", err)
+	}
+
+	cfg, err := config.TLS.Config()
+	if err != nil {
+		t.Fatalf("Failed to build TLS config: %s", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3, got %s", tls.VersionName(cfg.MinVersion))
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("Expected RequireAndVerifyClientCert, got %s", cfg.ClientAuth)
+	}
+	if len(cfg.Certificates) != 1 || cfg.ClientCAs == nil {
+		t.Errorf("Expected a certificate and client CAs, got %+v", cfg)
+	}
+
+	os.Unsetenv("TLS_MIN_VERSION")
+	os.Unsetenv("TLS_KEY")
+	config = Config{}
+	if err := New().ParseEnv(&config); err != nil {
+		t.Fatalf("Failed to parse TLS environment variables: %s", err)
+	}
+	if config.TLS.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.2 by default, got %s", tls.VersionName(config.TLS.MinVersion))
+	}
+	if _, err := config.TLS.Config(); err == nil {
+		t.Errorf("Expected an error for a certificate without key")
+	}
+}
+
+func TestMarshalUnsetTLSCertificate(t *testing.T) {
+	type Config struct {
+		Pair tls.Certificate `enviro:"pair"`
+	}
+
+	env, err := New().Marshal(&Config{})
+	if err != nil || len(env) != 0 {
+		t.Errorf("Expected an unset certificate to be omitted, got %v (%v)", env, err)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// setURL parses a url.URL and checks the constraints of the `url` option, a comma separated list of:
+//
+//   - scheme=a|b: the scheme must be one of the given schemes (case-insensitive).
+//   - host: the URL must have a host.
+//   - abs: the URL must be absolute.
+//
+// For example `envopt:"url:scheme=https|wss,host"`. Errors never include the URL password.
+func setURL(_ *Enviro, field reflect.Value, value string, opts Options) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		// The url.Error holds the raw URL, which may contain credentials
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	if constraints, ok := opts.Lookup("url"); ok {
+		for _, c := range strings.Split(constraints, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(c), "=")
+			switch name {
+			case "scheme":
+				schemes := strings.Split(strings.ToLower(arg), "|")
+				if !slices.Contains(schemes, strings.ToLower(u.Scheme)) {
+					return fmt.Errorf("invalid URL %q: scheme must be one of %s", u.Redacted(), strings.Join(schemes, ", "))
+				}
+			case "host":
+				if u.Host == "" {
+					return fmt.Errorf("invalid URL %q: missing host", u.Redacted())
+				}
+			case "abs":
+				if !u.IsAbs() {
+					return fmt.Errorf("invalid URL %q: not an absolute URL", u.Redacted())
+				}
+			default:
+				return fmt.Errorf("unsupported url option %q", name)
+			}
+		}
+	}
+
+	field.Set(reflect.ValueOf(*u))
+	return nil
+}
+
+func setMailAddress(_ *Enviro, field reflect.Value, value string, _ Options) error {
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return fmt.Errorf("invalid email address %q: %w", value, err)
+	}
+	field.Set(reflect.ValueOf(*addr))
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"crypto"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// boundField is a struct field bound to its environment variable, as seen by ParseEnvWithPrefix. Its name is
+// the path of the field from the root struct, e.g. "Proxy.URL", and its groups are the prefixes of the nested
+// structs that lead to it, e.g. ["proxy"], while tagKey is the key written in its enviro tag.
+type boundField struct {
+	key      string
+	tagKey   string
+	field    reflect.StructField
+	value    reflect.Value
+	opts     Options
+	name     string
+	groups   []string
+	def      string
+	required bool
+	secret   bool
+}
+
+// walkFields calls fn for every field of config bound to an environment variable, following nested structs with
+// the same prefix rules as ParseEnvWithPrefix. Nil pointers to nested structs are skipped, as are pointers back to
+// a struct being walked. It stops at the first error returned by fn.
+func walkFields(config any, prefix string, fn func(bf boundField) error) error {
+	val := reflect.ValueOf(config)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return errors.New("config must be a pointer to a struct")
+	}
+	parents := map[uintptr]struct{}{val.Pointer(): {}}
+	return walkStruct(val.Elem(), prefix, "", nil, parents, fn)
+}
+
+// walkStruct walks the fields of val. The parents set holds the address of the structs leading to val, so that a
+// cycle is not followed, while a struct reachable from several fields is walked for each of them.
+func walkStruct(val reflect.Value, prefix, path string, groups []string, parents map[uintptr]struct{}, fn func(bf boundField) error) error {
+	typ := val.Type()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		fieldType := typ.Field(i)
+		tag := fieldType.Tag.Get("enviro")
+
+		if tag == "" || strings.HasPrefix(tag, "nested:") {
+			if !field.CanSet() {
+				continue
+			}
+			var ptr uintptr
+			if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+				if field.IsNil() {
+					continue
+				}
+				ptr = field.Pointer()
+				if _, ok := parents[ptr]; ok {
+					continue
+				}
+				field = field.Elem()
+			}
+			if field.Kind() != reflect.Struct {
+				continue
+			}
+
+			var envPrefix string
+			if prefix != "" {
+				envPrefix = prefix + "_"
+			}
+			nestedGroups := groups
+			if tag != "" {
+				group := strings.TrimPrefix(tag, "nested:")
+				envPrefix += group
+				nestedGroups = append(groups[:len(groups):len(groups)], group)
+			}
+			if ptr != 0 {
+				parents[ptr] = struct{}{}
+			}
+			err := walkStruct(field, envPrefix, path+fieldType.Name+".", nestedGroups, parents, fn)
+			delete(parents, ptr)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		tagKey, omitprefix, required, secret := parseTag(tag)
+		envKey := tagKey
+		if !omitprefix && prefix != "" {
+			envKey = prefix + "_" + envKey
+		}
+
+		opts, err := parseOptions(fieldType.Tag.Get("envopt"))
+		if err != nil {
+			return fmt.Errorf("invalid envopt tag for field %s: %w", fieldType.Name, err)
+		}
+		opts.key = strings.ToUpper(envKey)
+		opts.field = fieldType
+
+		if err := fn(boundField{
+			key:      opts.key,
+			tagKey:   tagKey,
+			field:    fieldType,
+			value:    field,
+			opts:     opts,
+			name:     path + fieldType.Name,
+			groups:   groups,
+			def:      fieldType.Tag.Get("envdefault"),
+			required: required,
+			secret:   secret || isSecretType(fieldType.Type),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var secretTypes = map[reflect.Type]struct{}{
+	reflect.TypeOf((*crypto.PrivateKey)(nil)).Elem(): {},
+	reflect.TypeOf((*crypto.Signer)(nil)).Elem():     {},
+	reflect.TypeOf(tls.Certificate{}):                {},
+}
+
+// isSecretType reports whether values of typ are always secret, like private keys, even without the secret flag.
+// Pointers, slices, arrays, maps and Dynamic fields are secret if their elements are.
+func isSecretType(typ reflect.Type) bool {
+	for {
+		if _, ok := secretTypes[typ]; ok {
+			return true
+		}
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			typ = typ.Elem()
+		case reflect.Struct:
+			ptr := reflect.PointerTo(typ)
+			if !ptr.Implements(dynamicValueType) {
+				return false
+			}
+			typ = reflect.Zero(ptr).Interface().(dynamicValue).dynamicType()
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,390 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const defaultPollInterval = 2 * time.Second
+
+// Validator is implemented by configuration types that check their own consistency once parsed. A Watcher
+// rejects a reloaded configuration whose Validate method returns an error.
+type Validator interface {
+	Validate() error
+}
+
+// Change describes a configuration swapped by a Watcher.
+type Change[T any] struct {
+	// Old is the previous configuration.
+	Old *T
+	// New is the configuration now returned by Watcher.Load.
+	New *T
+	// Keys is the sorted list of environment variables whose value changed.
+	Keys []string
+}
+
+// Watcher holds a configuration of type T that can be reloaded while the program runs, on SIGHUP, when a watched
+// .env file or secret directory changes, or on demand with Reload. Each reload parses a new T, validates it and
+// atomically swaps it with the current one, so that Load never observes a partially updated configuration. If
+// parsing or validation fails, the current configuration is kept.
+//
+// Values are looked up in the secret directories first, then in the .env files (the last one added winning),
+// then with the lookup function of the Enviro instance. A secret directory holds a file per variable, named after
+// the variable or its lowercase form, whose content is the value without its trailing new line, as Docker and
+// Kubernetes mount secrets.
+//
+// The Dynamic fields of T are carried over from a configuration to the next, so that their OnChange callbacks keep
+// being called across reloads, and Dynamic fields read from an old configuration stay up to date.
+//
+// The setters must be called before Start.
+type Watcher[T any] struct {
+	e          *Enviro
+	current    atomic.Pointer[T]
+	reloadMu   sync.Mutex
+	mu         sync.Mutex
+	values     map[string]lookupResult
+	dotEnvs    []string
+	secretDirs []string
+	interval   time.Duration
+	signals    []os.Signal
+	validate   func(cfg *T) error
+	onError    func(err error)
+	subMu      sync.Mutex
+	subs       []subscriber[T]
+	nextSub    int
+}
+
+type subscriber[T any] struct {
+	id int
+	fn func(Change[T])
+}
+
+type lookupResult struct {
+	value  string
+	source string
+	ok     bool
+}
+
+// NewWatcher returns a Watcher that parses T with e. The configuration is not loaded until Start or Reload is
+// called.
+func NewWatcher[T any](e *Enviro) *Watcher[T] {
+	return &Watcher[T]{
+		e:        e,
+		interval: defaultPollInterval,
+		signals:  []os.Signal{syscall.SIGHUP},
+	}
+}
+
+// AddDotEnv adds a .env file to the sources of the configuration. The file is watched for changes, and a missing
+// file is treated as empty.
+func (w *Watcher[T]) AddDotEnv(name string) {
+	w.dotEnvs = append(w.dotEnvs, name)
+}
+
+// AddSecretDir adds a directory of secret files to the sources of the configuration. The directory is watched
+// for changes, and a missing directory is treated as empty.
+func (w *Watcher[T]) AddSecretDir(dir string) {
+	w.secretDirs = append(w.secretDirs, dir)
+}
+
+// SetPollInterval sets the interval at which the .env files and secret directories are checked for changes. The
+// default is 2 seconds, and a negative or zero interval disables polling.
+func (w *Watcher[T]) SetPollInterval(d time.Duration) {
+	w.interval = d
+}
+
+// SetSignals sets the signals that trigger a reload, SIGHUP by default. Calling SetSignals without argument
+// disables reloading on signals.
+func (w *Watcher[T]) SetSignals(sig ...os.Signal) {
+	w.signals = sig
+}
+
+// SetValidator sets a function that validates a configuration before it is swapped in, in addition to the
+// Validate method of T, if any.
+func (w *Watcher[T]) SetValidator(fn func(cfg *T) error) {
+	w.validate = fn
+}
+
+// SetErrorHandler sets a function called with the error of reloads triggered by a signal or a file change.
+func (w *Watcher[T]) SetErrorHandler(fn func(err error)) {
+	w.onError = fn
+}
+
+// Load returns the current configuration, or nil if it was never loaded. The returned value must not be
+// modified.
+func (w *Watcher[T]) Load() *T {
+	return w.current.Load()
+}
+
+// Subscribe registers fn to be called after each reload that changes the configuration. Subscribers are called
+// one after the other, in the order they subscribed, from the goroutine that reloaded the configuration, once the
+// new configuration is swapped in. They may call the methods of the Watcher, such as Describe, but not Reload. The
+// files of the old configuration are closed once all the subscribers returned. The returned function removes the
+// subscription.
+func (w *Watcher[T]) Subscribe(fn func(Change[T])) (unsubscribe func()) {
+	w.subMu.Lock()
+	defer w.subMu.Unlock()
+	id := w.nextSub
+	w.nextSub++
+	w.subs = append(w.subs, subscriber[T]{id: id, fn: fn})
+	return func() {
+		w.subMu.Lock()
+		defer w.subMu.Unlock()
+		for i, sub := range w.subs {
+			if sub.id == id {
+				w.subs = append(w.subs[:i:i], w.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Start loads the configuration and starts watching for signals and file changes until ctx is done. It returns
+// the error of the initial load, in which case nothing is watched.
+func (w *Watcher[T]) Start(ctx context.Context) error {
+	state := w.sourceState()
+	if err := w.Reload(); err != nil {
+		return err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	if len(w.signals) > 0 {
+		signal.Notify(sigs, w.signals...)
+	}
+	go w.watch(ctx, sigs, state)
+	return nil
+}
+
+func (w *Watcher[T]) watch(ctx context.Context, sigs chan os.Signal, state string) {
+	defer signal.Stop(sigs)
+
+	var tick <-chan time.Time
+	if w.interval > 0 && (len(w.dotEnvs) > 0 || len(w.secretDirs) > 0) {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigs:
+			w.reload()
+		case <-tick:
+			if next := w.sourceState(); next != state {
+				state = next
+				w.reload()
+			}
+		}
+	}
+}
+
+func (w *Watcher[T]) reload() {
+	if err := w.Reload(); err != nil && w.onError != nil {
+		w.onError(err)
+	}
+}
+
+// Reload parses and validates a new configuration and swaps it with the current one. If any variable changed, the
+// OnChange callbacks of the Dynamic fields whose value changed are called, then the subscribers, after which the
+// files of the old configuration are closed. On error, or if nothing changed, the current configuration is kept
+// and the files opened by the new one are closed. Concurrent reloads run one after the other, so that changes are
+// notified in order.
+func (w *Watcher[T]) Reload() error {
+	// The reload lock is held until the old configuration is released, while w.mu only guards the swap, so that
+	// Load and Describe don't wait for the subscribers.
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	w.mu.Lock()
+	old, cfg, keys, notify, err := w.swap()
+	w.mu.Unlock()
+	if err != nil || old == nil {
+		return err
+	}
+
+	for _, fn := range notify {
+		fn()
+	}
+
+	w.subMu.Lock()
+	subs := make([]subscriber[T], len(w.subs))
+	copy(subs, w.subs)
+	w.subMu.Unlock()
+	for _, sub := range subs {
+		sub.fn(Change[T]{Old: old, New: cfg, Keys: keys})
+	}
+	if err := w.e.release(old); err != nil {
+		return fmt.Errorf("failed to close the previous configuration: %w", err)
+	}
+	return nil
+}
+
+// swap parses and validates a new configuration, and swaps it with the current one if any variable changed,
+// carrying the Dynamic fields over. It returns the old and new configurations if they were swapped, with the
+// functions calling the OnChange callbacks of the Dynamic fields, and must be called with w.mu held.
+func (w *Watcher[T]) swap() (old, cfg *T, keys []string, notify []func(), err error) {
+	lookup, err := w.sources()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	values := make(map[string]lookupResult)
+	record := func(key string) (string, bool) {
+		value, source, ok := lookup(key)
+		values[key] = lookupResult{value: value, source: source, ok: ok}
+		return value, ok
+	}
+
+	cfg = new(T)
+	if err := w.e.parse(cfg, w.e.prefix, record); err != nil {
+		// Files may have been opened before the error
+		w.e.release(cfg)
+		return nil, nil, nil, nil, err
+	}
+	if err := w.validateConfig(cfg); err != nil {
+		w.e.release(cfg)
+		return nil, nil, nil, nil, err
+	}
+
+	old = w.current.Load()
+	keys = changedKeys(w.values, values)
+	if old != nil && len(keys) == 0 {
+		w.e.release(cfg)
+		return nil, nil, nil, nil, nil
+	}
+	if old != nil {
+		if notify, err = w.e.carryDynamic(old, cfg); err != nil {
+			w.e.release(cfg)
+			return nil, nil, nil, nil, err
+		}
+	}
+	w.values = values
+	w.current.Store(cfg)
+	return old, cfg, keys, notify, nil
+}
+
+func (w *Watcher[T]) validateConfig(cfg *T) error {
+	if v, ok := any(cfg).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+	if w.validate != nil {
+		if err := w.validate(cfg); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// sources reads the .env files and returns the lookup function over all the sources, which also reports the
+// source of each value.
+func (w *Watcher[T]) sources() (func(key string) (value, source string, ok bool), error) {
+	type entry struct {
+		value  string
+		source string
+	}
+	dotEnv := make(map[string]entry)
+	for _, name := range w.dotEnvs {
+		d, err := LoadDotEnv(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", name, err)
+		}
+		for _, kv := range d.Environ() {
+			k, v, _ := strings.Cut(kv, "=")
+			dotEnv[k] = entry{value: v, source: "dotenv:" + name}
+		}
+	}
+
+	next := w.e.lookupFunc()
+	secretDirs := w.secretDirs
+	return func(key string) (string, string, bool) {
+		for _, dir := range secretDirs {
+			for _, name := range []string{key, strings.ToLower(key)} {
+				path := filepath.Join(dir, name)
+				if data, err := os.ReadFile(path); err == nil {
+					return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), "secret:" + path, true
+				}
+			}
+		}
+		if en, ok := dotEnv[key]; ok {
+			return en.value, en.source, true
+		}
+		value, ok := next(key)
+		return value, SourceEnv, ok
+	}, nil
+}
+
+// Describe describes the current configuration, with the source of each value as of the last reload. It
+// returns an error if the configuration was never loaded.
+func (w *Watcher[T]) Describe() ([]FieldInfo, error) {
+	w.mu.Lock()
+	cfg := w.current.Load()
+	values := w.values
+	w.mu.Unlock()
+	if cfg == nil {
+		return nil, errors.New("configuration not loaded")
+	}
+
+	return w.e.describe(cfg, func(key string) (string, string, bool) {
+		v := values[key]
+		return v.value, v.source, v.ok
+	})
+}
+
+// sourceState returns a summary of the size and modification time of the watched files, which changes when any
+// of them is written, created or removed.
+func (w *Watcher[T]) sourceState() string {
+	var sb strings.Builder
+	stat := func(name string) {
+		if info, err := os.Stat(name); err == nil {
+			fmt.Fprintf(&sb, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	for _, name := range w.dotEnvs {
+		stat(name)
+	}
+	for _, dir := range w.secretDirs {
+		stat(dir)
+		// Kubernetes updates secrets by swapping a symbolic link, so files are stat'ed through links
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			stat(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return sb.String()
+}
+
+func changedKeys(old, values map[string]lookupResult) []string {
+	var keys []string
+	for key, v := range values {
+		if o, ok := old[key]; !ok || o.ok != v.ok || o.value != v.value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range old {
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
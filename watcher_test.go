@@ -0,0 +1,263 @@
+// Copyright 2024 Sylvain Müller. All rights reserved.
+// Mount of this source code is governed by a MIT License that can be found
+// at https://github.com/tigerwill90/enviro/blob/master/LICENSE.txt.
+
+package enviro
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type watchedConfig struct {
+	Level    string `enviro:"level"`
+	Limit    int    `enviro:"limit"`
+	Password string `enviro:"db_password"`
+}
+
+func (c *watchedConfig) Validate() error {
+	if c.Limit < 0 {
+		return errors.New("limit must be positive")
+	}
+	return nil
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	dotEnv := filepath.Join(dir, ".env")
+	secrets := filepath.Join(dir, "secrets")
+	if err := os.WriteFile(dotEnv, []byte("LEVEL=info\nLIMIT=10\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(secrets, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(secrets, "db_password"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := NewWatcher[watchedConfig](New())
+	w.AddDotEnv(dotEnv)
+	w.AddSecretDir(secrets)
+	w.SetPollInterval(10 * time.Millisecond)
+	w.SetSignals(syscall.SIGUSR2)
+	errs := make(chan error, 1)
+	w.SetErrorHandler(func(err error) {
+		errs <- err
+	})
+	changes := make(chan Change[watchedConfig], 1)
+	w.Subscribe(func(c Change[watchedConfig]) {
+		changes <- c
+	})
+
+	if err := w.Start(ctx); err != nil {
+		t.Fatalf("Failed to start watcher: %s", err)
+	}
+	expected := watchedConfig{Level: "info", Limit: 10, Password: "s3cr3t"}
+	if cfg := w.Load(); !reflect.DeepEqual(*cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, *cfg)
+	}
+
+	// A file change triggers a reload
+	if err := os.WriteFile(dotEnv, []byte("LEVEL=info\nLIMIT=200\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-changes:
+		if c.Old.Limit != 10 || c.New.Limit != 200 || !reflect.DeepEqual(c.Keys, []string{"LIMIT"}) {
+			t.Errorf("Unexpected change %+v", c)
+		}
+		if w.Load() != c.New {
+			t.Errorf("Expected the new config to be swapped in")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change after updating the .env file")
+	}
+
+	// An invalid configuration is rejected and the current one is kept
+	if err := os.WriteFile(dotEnv, []byte("LEVEL=info\nLIMIT=-1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected an error for an invalid configuration")
+	}
+	if w.Load().Limit != 200 {
+		t.Errorf("Expected the current config to be kept, got %+v", w.Load())
+	}
+
+	// A signal (or the file change) triggers a reload, the secret directory and the .env file take precedence over the environment
+	os.Setenv("LEVEL", "debug")
+	os.Setenv("DB_PASSWORD", "ignored")
+	defer os.Unsetenv("LEVEL")
+	defer os.Unsetenv("DB_PASSWORD")
+	if err := os.WriteFile(dotEnv, []byte("LIMIT=300\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case c := <-changes:
+		expected := watchedConfig{Level: "debug", Limit: 300, Password: "s3cr3t"}
+		if !reflect.DeepEqual(*c.New, expected) || !reflect.DeepEqual(c.Keys, []string{"LEVEL", "LIMIT"}) {
+			t.Errorf("Unexpected change %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change after the signal")
+	}
+
+	// Reloading without any change does not notify subscribers
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	select {
+	case c := <-changes:
+		t.Errorf("Unexpected change %+v", c)
+	default:
+	}
+}
+
+func TestWatcherReloadFiles(t *testing.T) {
+	type Config struct {
+		Level string   `enviro:"level"`
+		Input *os.File `enviro:"input"`
+	}
+
+	input := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(input, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"LEVEL": "info", "INPUT": input}
+
+	e := New()
+	e.SetLookupFunc(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	w := NewWatcher[Config](e)
+	w.SetValidator(func(cfg *Config) error {
+		if cfg.Level == "bad" {
+			return errors.New("bad level")
+		}
+		return nil
+	})
+
+	var calls []string
+	w.Subscribe(func(c Change[Config]) {
+		// Subscribers may use the watcher
+		if _, err := w.Describe(); err != nil {
+			t.Errorf("Failed to describe: %s", err)
+		}
+		calls = append(calls, "first")
+	})
+	unsubscribe := w.Subscribe(func(c Change[Config]) {
+		calls = append(calls, "second")
+	})
+	w.Subscribe(func(c Change[Config]) {
+		calls = append(calls, "third")
+	})
+	unsubscribe()
+
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	first := w.Load()
+
+	// The files of configurations that are not swapped in are closed and released
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	env["LEVEL"] = "bad"
+	if err := w.Reload(); err == nil {
+		t.Errorf("Expected an error for an invalid configuration")
+	}
+	if w.Load() != first || len(e.files) != 1 {
+		t.Errorf("Expected the first config to be kept with 1 tracked file, got %d", len(e.files))
+	}
+
+	// The files of the old configuration are closed once the subscribers returned
+	env["LEVEL"] = "debug"
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %s", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"first", "third"}) {
+		t.Errorf("Expected the subscribers to be called in order, got %v", calls)
+	}
+	if _, err := first.Input.Read(make([]byte, 1)); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Expected the old file to be closed, got %v", err)
+	}
+	if _, err := w.Load().Input.Read(make([]byte, 1)); err != nil {
+		t.Errorf("Expected the new file to be open, got %v", err)
+	}
+	if len(e.files) != 1 {
+		t.Errorf("Expected 1 tracked file, got %d", len(e.files))
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("Failed to close files: %s", err)
+	}
+}
+
+func TestWatcherConcurrentReload(t *testing.T) {
+	type Config struct {
+		Limit int `enviro:"limit"`
+	}
+
+	// Every lookup returns a new value, so that every reload changes the configuration
+	var limit atomic.Int64
+	e := New()
+	e.SetLookupFunc(func(key string) (string, bool) {
+		return strconv.FormatInt(limit.Add(1), 10), true
+	})
+	w := NewWatcher[Config](e)
+
+	var mu sync.Mutex
+	var changes []Change[Config]
+	w.Subscribe(func(c Change[Config]) {
+		// Give a concurrent reload the chance to overtake this one
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, c)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if err := w.Reload(); err != nil {
+					t.Errorf("Failed to reload: %s", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The first reload has no old configuration to notify about
+	if len(changes) != 99 {
+		t.Fatalf("Expected 99 changes, got %d", len(changes))
+	}
+	for i := 1; i < len(changes); i++ {
+		if changes[i].Old != changes[i-1].New {
+			t.Fatalf("Expected change %d to follow change %d", i, i-1)
+		}
+	}
+	if last := changes[len(changes)-1].New; w.Load() != last {
+		t.Errorf("Expected the last change to hold the current config")
+	}
+}